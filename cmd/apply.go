@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/tabed23/k8s-resource-tuner/internal/apply"
+	"github.com/tabed23/k8s-resource-tuner/internal/models"
+	"github.com/tabed23/k8s-resource-tuner/internal/report"
+)
+
+func runApply(args []string) int {
+	c := newCommand("apply", "Patch workloads with the recommended requests and limits.", "table")
+	c.withDryRun("validate the patches with a server-side dry run without persisting them")
+	threshold := c.fs.Float64("threshold", 10, "only patch workloads with a change larger than this percentage of the current value")
+	var allowed listFlag
+	c.fs.Var(&allowed, "allow-namespace", "glob or re:<regex> pattern of a namespace apply may patch, can be repeated (overrides apply.allowedNamespaces)")
+	maxChange := c.fs.Float64("max-change", 0, "maximum change of a value in one run, in percent of the current value, 0 disables the cap (default 50 from apply.maxChangePercent)")
+	protectMemory := c.fs.Bool("protect-memory", true, "never lower memory below the highest usage observed")
+	cfg, err := c.parse(args)
+	if err != nil {
+		return usageStatus(err)
+	}
+	c.fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "allow-namespace":
+			cfg.Apply.AllowedNamespaces = allowed
+		case "max-change":
+			cfg.Apply.MaxChangePercent = *maxChange
+		case "protect-memory":
+			cfg.Apply.ProtectMemory = *protectMemory
+		}
+	})
+	if err := validate(cfg); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+	opts := apply.Options{
+		DryRun:            c.dryRun,
+		AllowedNamespaces: cfg.Apply.AllowedNamespaces,
+		MaxChangePercent:  cfg.Apply.MaxChangePercent,
+		ProtectMemory:     cfg.Apply.ProtectMemory,
+	}
+	if len(opts.AllowedNamespaces) == 0 && !opts.DryRun {
+		fmt.Fprintln(os.Stderr, "Warning: no namespace is approved for apply, set -allow-namespace or apply.allowedNamespaces")
+	}
+	if c.output != "table" && c.output != "json" {
+		fmt.Fprintf(os.Stderr, "Error: unknown output format %q, use table or json\n", c.output)
+		return exitUsage
+	}
+
+	clusters, connErr := connectAll(cfg)
+	reportData, scanErr := generateReport(cfg, clusters)
+	errs := []error{connErr, scanErr}
+
+	opts.RunID = reportData.RunID
+	fmt.Fprintf(os.Stderr, "Apply run %s\n", reportData.RunID)
+
+	byName := map[string]*cluster{}
+	for _, cl := range clusters {
+		byName[cl.name] = cl
+	}
+	results := []apply.Result{}
+	var patched []models.ReportEntry
+	for _, e := range reportData.Entries {
+		if !report.NeedsChange(e, *threshold) {
+			continue
+		}
+		guarded, notes := apply.Guard(e, opts)
+		for _, note := range notes {
+			fmt.Fprintf(os.Stderr, "%s %s/%s: %s\n", e.Workload.Kind, e.Workload.Namespace, e.Workload.Name, note)
+		}
+		if !report.NeedsChange(guarded, *threshold) {
+			continue
+		}
+		result := apply.Apply(byName[e.Cluster].clientset, guarded, opts)
+		if len(notes) > 0 && result.Message == "" {
+			result.Message = fmt.Sprintf("%d values adjusted by safety gates", len(notes))
+		}
+		if result.Status == "failed" {
+			errs = append(errs, errors.New(result.Message))
+		}
+		if result.Status == "patched" {
+			patched = append(patched, guarded)
+		}
+		results = append(results, result)
+	}
+	recordRun(cfg, "apply", reportData, patched)
+
+	if c.output == "json" {
+		if encErr := writeJSON(results); encErr != nil {
+			return fail(encErr)
+		}
+	} else {
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "CLUSTER\tNAMESPACE\tKIND\tNAME\tSTATUS\tMESSAGE")
+		for _, r := range results {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", dash(r.Cluster), r.Namespace, r.Kind, r.Name, r.Status, r.Message)
+		}
+		tw.Flush()
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return fail(err)
+	}
+	return exitOK
+}
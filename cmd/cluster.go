@@ -0,0 +1,227 @@
+package main
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tabed23/k8s-resource-tuner/internal/config"
+	"github.com/tabed23/k8s-resource-tuner/internal/k8s"
+	"github.com/tabed23/k8s-resource-tuner/internal/models"
+	"github.com/tabed23/k8s-resource-tuner/internal/patch"
+	"github.com/tabed23/k8s-resource-tuner/internal/prometheus"
+	"github.com/tabed23/k8s-resource-tuner/internal/report"
+	"github.com/tabed23/k8s-resource-tuner/internal/vpa"
+	"k8s.io/client-go/kubernetes"
+)
+
+// cluster is an open connection to one scan target. name tags report
+// entries and is empty for single-cluster runs.
+type cluster struct {
+	name       string
+	clientset  *kubernetes.Clientset
+	prom       *prometheus.PromClient
+	namespaces []string
+}
+
+// targets returns the configured clusters. A single-cluster run uses the
+// top-level kube and prometheus settings.
+func targets(cfg config.Config) []config.ClusterConfig {
+	if len(cfg.Clusters) > 0 {
+		return cfg.Clusters
+	}
+	return []config.ClusterConfig{{Prometheus: cfg.Prometheus}}
+}
+
+// kubeTargets returns the kubeconfig and context of every cluster by name,
+// as connect resolves them, for the generated kubectl commands.
+func kubeTargets(cfg config.Config) map[string]patch.KubeTarget {
+	kubeTargets := map[string]patch.KubeTarget{}
+	for _, t := range targets(cfg) {
+		kt := patch.KubeTarget{Kubeconfig: cfg.Kube.Kubeconfig, Context: cfg.Kube.Context}
+		if t.Kubeconfig != "" {
+			kt.Kubeconfig = t.Kubeconfig
+		}
+		if t.Context != "" {
+			kt.Context = t.Context
+		}
+		kubeTargets[t.Name] = kt
+	}
+	return kubeTargets
+}
+
+func connect(cfg config.Config, target config.ClusterConfig) (*cluster, error) {
+	clientOpts := k8s.ClientOptions{
+		Kubeconfig:        cfg.Kube.Kubeconfig,
+		Context:           cfg.Kube.Context,
+		ImpersonateUser:   cfg.Kube.ImpersonateUser,
+		ImpersonateGroups: cfg.Kube.ImpersonateGroups,
+		QPS:               cfg.Kube.QPS,
+		Burst:             cfg.Kube.Burst,
+	}
+	if target.Context != "" {
+		clientOpts.Context = target.Context
+	}
+	if target.Kubeconfig != "" {
+		clientOpts.Kubeconfig = target.Kubeconfig
+	}
+	clientSet, err := k8s.InitKubeClient(clientOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	namespaces := cfg.Namespaces.Names
+	if cfg.Namespaces.All {
+		namespaces, err = k8s.DiscoverNamespaces(clientSet, k8s.NamespaceFilter{
+			Include:       cfg.Namespaces.Include,
+			Exclude:       cfg.Namespaces.Exclude,
+			LabelSelector: cfg.Namespaces.Selector,
+			IncludeSystem: cfg.Namespaces.IncludeSystem,
+		})
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(os.Stderr, "Discovered %d namespaces: %s\n", len(namespaces), strings.Join(namespaces, ","))
+	}
+
+	promCfg := cfg.ClusterPrometheus(target)
+	prom := prometheus.NewPromClient(promCfg.URL)
+	prom.Client.Timeout = promCfg.Timeout
+	prom.BearerToken = promCfg.BearerToken
+	prom.Username = promCfg.Username
+	prom.Password = promCfg.Password
+
+	return &cluster{
+		name:       target.Name,
+		clientset:  clientSet,
+		prom:       prom,
+		namespaces: namespaces,
+	}, nil
+}
+
+// connectAll connects to every configured cluster. A cluster that cannot be
+// reached does not stop the others, its error is returned next to the
+// clusters that did connect.
+func connectAll(cfg config.Config) ([]*cluster, error) {
+	var clusters []*cluster
+	var errs []error
+	for _, target := range targets(cfg) {
+		c, err := connect(cfg, target)
+		if err != nil {
+			errs = append(errs, clusterError(target.Name, err))
+			continue
+		}
+		clusters = append(clusters, c)
+	}
+	return clusters, errors.Join(errs...)
+}
+
+// forEachCluster calls fn for every cluster concurrently and returns the
+// errors of all of them joined together.
+func forEachCluster(clusters []*cluster, fn func(c *cluster) error) error {
+	errs := make([]error, len(clusters))
+	var wg sync.WaitGroup
+	for i, c := range clusters {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = clusterError(c.name, fn(c))
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+func clusterError(name string, err error) error {
+	if err == nil || name == "" {
+		return err
+	}
+	return fmt.Errorf("cluster %s: %v", name, err)
+}
+
+// generateReport builds the merged report of every selected namespace in
+// every cluster. A namespace that fails does not stop the others, the
+// returned error lists every failure next to the partial report.
+func generateReport(cfg config.Config, clusters []*cluster) (models.Report, error) {
+	var mu sync.Mutex
+	var reports []models.Report
+	err := forEachCluster(clusters, func(c *cluster) error {
+		r, err := scanCluster(cfg, c)
+		mu.Lock()
+		reports = append(reports, r)
+		mu.Unlock()
+		return err
+	})
+
+	combinedReport := report.MergeReports(reports)
+	combinedReport.Timestamp = time.Now()
+	combinedReport.RunID = newRunID(combinedReport.Timestamp)
+	return combinedReport, err
+}
+
+// newRunID identifies a run by its start time and a random suffix, so runs
+// started in the same second stay distinct.
+func newRunID(t time.Time) string {
+	suffix := make([]byte, 3)
+	rand.Read(suffix)
+	return fmt.Sprintf("%s-%x", t.UTC().Format("20060102T150405Z"), suffix)
+}
+
+// scanCluster generates the report of every selected namespace in one cluster.
+func scanCluster(cfg config.Config, c *cluster) (models.Report, error) {
+	var allEntries []models.ReportEntry
+	var errs []error
+
+	policies, err := cfg.PolicySet()
+	if err != nil {
+		return models.Report{}, err
+	}
+	pricing, priced, err := cfg.CostModel()
+	if err != nil {
+		return models.Report{}, err
+	}
+
+	for _, ns := range c.namespaces {
+		reportData, err := report.GenrateReport(c.clientset, c.prom, ns, report.Options{
+			Selector:           cfg.Selector,
+			AnnotationSelector: cfg.AnnotationSelector,
+			Cluster:            c.name,
+			Lookback:           cfg.Lookback,
+			Step:               cfg.Step,
+			Policies:           policies,
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error generating report for %s: %v\n", ns, err)
+			errs = append(errs, fmt.Errorf("namespace %s: %v", ns, err))
+			continue
+		}
+		if cfg.Outputs.CompareVPA {
+			vpas, err := k8s.ListVPAs(c.clientset, ns)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: namespace %s: %v\n", ns, err)
+			}
+			vpa.AttachTargets(reportData.Entries, vpas)
+		}
+		allEntries = append(allEntries, reportData.Entries...)
+	}
+
+	if priced {
+		nodes, err := k8s.NodeLabels(c.clientset)
+		if err != nil {
+			// Without node labels every pod gets the default price.
+			fmt.Fprintf(os.Stderr, "Warning: %v, node pool prices are not applied\n", err)
+		}
+		pricing.Estimate(allEntries, nodes)
+	}
+
+	return models.Report{
+		Timestamp: time.Now(),
+		Lookback:  cfg.Lookback.String(),
+		Entries:   allEntries,
+		Summary:   report.Summarize(allEntries),
+	}, errors.Join(errs...)
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/tabed23/k8s-resource-tuner/internal/history"
+	"github.com/tabed23/k8s-resource-tuner/internal/models"
+	"github.com/tabed23/k8s-resource-tuner/internal/report"
+)
+
+func runCompare(args []string) int {
+	c := newCommand("compare", "Compare two recorded runs, or two JSON reports: workloads that appeared or disappeared,\nrecommendations that moved, changes that reduced waste and the monthly cost.", "markdown")
+	c.fs.Lookup("o").Usage = "output format: markdown, pdf or json"
+	db := c.fs.String("db", "", "path of the history database (overrides history.path)")
+	from := c.fs.String("from", "", "earlier run ID or JSON report file (default the run before -to)")
+	to := c.fs.String("to", "", "later run ID or JSON report file (default the latest recorded run)")
+	threshold := c.fs.Float64("threshold", 10, "ignore recommendations that moved less than this percentage")
+	out := c.fs.String("out", "", "file to write to (default stdout, k8s_resource_compare_<time>.pdf for pdf)")
+	cfg, err := c.parse(args)
+	if err != nil {
+		return usageStatus(err)
+	}
+	if *db != "" {
+		cfg.History.Path = *db
+	}
+	if c.output != "markdown" && c.output != "pdf" && c.output != "json" {
+		fmt.Fprintf(os.Stderr, "Error: unknown output format %q, use markdown, pdf or json\n", c.output)
+		return exitUsage
+	}
+
+	var store *history.Store
+	if cfg.History.Path != "" {
+		store, err = history.Open(cfg.History.Path)
+		if err != nil {
+			return fail(err)
+		}
+		defer store.Close()
+	}
+	if *from == "" || *to == "" {
+		if store == nil {
+			fmt.Fprintln(os.Stderr, "Error: -from and -to are required without -db or history.path")
+			return exitUsage
+		}
+		if err := defaultRuns(store, from, to); err != nil {
+			return fail(err)
+		}
+	}
+
+	before, err := loadReport(store, *from)
+	if err != nil {
+		return fail(err)
+	}
+	after, err := loadReport(store, *to)
+	if err != nil {
+		return fail(err)
+	}
+	comparison := report.CompareRuns(before, after, *threshold)
+
+	render := func(w io.Writer) error {
+		switch c.output {
+		case "json":
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			return enc.Encode(comparison)
+		case "pdf":
+			return report.ComparisonPDF(w, comparison)
+		}
+		return report.ComparisonMarkdown(w, comparison)
+	}
+	if *out == "" && c.output == "pdf" {
+		*out = fmt.Sprintf("k8s_resource_compare_%s.pdf", after.Timestamp.Format("20060102_150405"))
+	}
+	if *out == "" {
+		if err := render(os.Stdout); err != nil {
+			return fail(fmt.Errorf("failed to render %s comparison: %v", c.output, err))
+		}
+		return exitOK
+	}
+	f, err := os.Create(*out)
+	if err != nil {
+		return fail(fmt.Errorf("failed to save comparison: %v", err))
+	}
+	if err := render(f); err != nil {
+		f.Close()
+		return fail(fmt.Errorf("failed to render %s comparison: %v", c.output, err))
+	}
+	if err := f.Close(); err != nil {
+		return fail(fmt.Errorf("failed to save comparison: %v", err))
+	}
+	fmt.Fprintf(os.Stderr, "Comparison saved as: %s\n", *out)
+	return exitOK
+}
+
+// defaultRuns fills in the latest recorded run for an empty to, and the run
+// recorded before to for an empty from.
+func defaultRuns(store *history.Store, from, to *string) error {
+	runs, err := store.Runs(time.Time{})
+	if err != nil {
+		return err
+	}
+	if *to == "" {
+		if len(runs) == 0 {
+			return fmt.Errorf("no run is recorded in the history")
+		}
+		*to = runs[len(runs)-1].ID
+	}
+	if *from == "" {
+		for i, r := range runs {
+			if r.ID == *to && i > 0 {
+				*from = runs[i-1].ID
+				return nil
+			}
+		}
+		return fmt.Errorf("no run is recorded before %s, set -from", *to)
+	}
+	return nil
+}
+
+// loadReport reads a JSON report file, or the report of a recorded run.
+func loadReport(store *history.Store, ref string) (models.Report, error) {
+	if info, err := os.Stat(ref); err == nil && !info.IsDir() {
+		data, err := os.ReadFile(ref)
+		if err != nil {
+			return models.Report{}, fmt.Errorf("failed to read report: %v", err)
+		}
+		var r models.Report
+		if err := json.Unmarshal(data, &r); err != nil {
+			return models.Report{}, fmt.Errorf("failed to parse report %s: %v", ref, err)
+		}
+		return r, nil
+	}
+	if store == nil {
+		return models.Report{}, fmt.Errorf("%s is not a report file, set -db or history.path to compare recorded runs", ref)
+	}
+	return store.Report(ref)
+}
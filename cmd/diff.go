@@ -0,0 +1,66 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/tabed23/k8s-resource-tuner/internal/report"
+)
+
+func runDiff(args []string) int {
+	c := newCommand("diff", fmt.Sprintf("Compare current requests and limits with the recommendations.\nExits with status %d when a value differs by more than the threshold.", exitChanges), "table")
+	threshold := c.fs.Float64("threshold", 10, "ignore changes smaller than this percentage of the current value")
+	cfg, err := c.parse(args)
+	if err != nil {
+		return usageStatus(err)
+	}
+	if c.output != "table" && c.output != "json" {
+		fmt.Fprintf(os.Stderr, "Error: unknown output format %q, use table or json\n", c.output)
+		return exitUsage
+	}
+
+	clusters, connErr := connectAll(cfg)
+	reportData, err := generateReport(cfg, clusters)
+	err = errors.Join(connErr, err)
+	recordRun(cfg, "diff", reportData, nil)
+	changes := []report.Change{}
+	for _, e := range reportData.Entries {
+		for _, ch := range report.Compare(e) {
+			if ch.Exceeds(*threshold) {
+				changes = append(changes, ch)
+			}
+		}
+	}
+
+	if c.output == "json" {
+		if encErr := writeJSON(changes); encErr != nil {
+			return fail(encErr)
+		}
+	} else {
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "CLUSTER\tNAMESPACE\tKIND\tNAME\tCONTAINER\tRESOURCE\tCURRENT\tRECOMMENDED\tDELTA")
+		for _, ch := range changes {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s %s\t%s\t%s\t%s\n",
+				dash(ch.Cluster), ch.Namespace, ch.Kind, ch.Workload, ch.Container, ch.Resource, ch.Field,
+				dash(ch.Current), dash(ch.Recommended), deltaString(ch))
+		}
+		tw.Flush()
+	}
+
+	if err != nil {
+		return fail(err)
+	}
+	if len(changes) > 0 {
+		return exitChanges
+	}
+	return exitOK
+}
+
+func deltaString(ch report.Change) string {
+	if ch.Current == "" || ch.Recommended == "" {
+		return "-"
+	}
+	return fmt.Sprintf("%+.1f%%", ch.DeltaPercent)
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/tabed23/k8s-resource-tuner/internal/config"
+	"github.com/tabed23/k8s-resource-tuner/internal/report"
+)
+
+// listFlag is a comma-separated flag that can also be repeated.
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(value string) error {
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*l = append(*l, v)
+		}
+	}
+	return nil
+}
+
+// command is a subcommand with the flags shared by every subcommand:
+// configuration, cluster connection and workload scoping.
+type command struct {
+	fs *flag.FlagSet
+
+	configPath string
+
+	namespaces              listFlag
+	allNamespaces           bool
+	includeNamespaces       listFlag
+	excludeNamespaces       listFlag
+	namespaceSelector       string
+	includeSystemNamespaces bool
+	selector                string
+	annotationSelector      string
+
+	kubeconfig        string
+	kubeContext       string
+	impersonateUser   string
+	impersonateGroups listFlag
+	qps               float64
+	burst             int
+
+	prometheusURL string
+	clusters      listFlag
+
+	output string
+	dryRun bool
+}
+
+func newCommand(name, usage, defaultOutput string) *command {
+	c := &command{fs: flag.NewFlagSet(name, flag.ContinueOnError)}
+	fs := c.fs
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: k8s-resource-tuner %s [flags]\n\n%s\n\nFlags:\n", name, usage)
+		fs.PrintDefaults()
+	}
+
+	fs.StringVar(&c.configPath, "config", "", "path to the YAML configuration file")
+
+	fs.Var(&c.namespaces, "n", "comma-separated namespaces to scan (default \"test\")")
+	fs.Var(&c.namespaces, "namespaces", "alias for -n")
+	fs.BoolVar(&c.allNamespaces, "all-namespaces", false, "discover and scan every namespace in the cluster")
+	fs.Var(&c.includeNamespaces, "include-namespaces", "glob or re:<regex> patterns of namespaces to scan in -all-namespaces mode")
+	fs.Var(&c.excludeNamespaces, "exclude-namespaces", "glob or re:<regex> patterns of namespaces to skip in -all-namespaces mode")
+	fs.StringVar(&c.namespaceSelector, "namespace-selector", "", "label selector namespaces must match in -all-namespaces mode (e.g. team=payments)")
+	fs.BoolVar(&c.includeSystemNamespaces, "include-system-namespaces", false, "do not skip kube-system, kube-public and kube-node-lease")
+	fs.StringVar(&c.selector, "l", "", "label selector workloads must match (e.g. app.kubernetes.io/part-of=checkout)")
+	fs.StringVar(&c.annotationSelector, "annotation-selector", "", "selector workloads' annotations must match, in label selector syntax (e.g. resource-tuner/owner=payments)")
+
+	fs.StringVar(&c.kubeconfig, "kubeconfig", "", "path to the kubeconfig file (defaults to in-cluster config, then $KUBECONFIG or ~/.kube/config)")
+	fs.StringVar(&c.kubeContext, "context", "", "kubeconfig context to use")
+	fs.StringVar(&c.impersonateUser, "as", "", "user to impersonate")
+	fs.Var(&c.impersonateGroups, "as-group", "group to impersonate, can be repeated")
+	fs.Float64Var(&c.qps, "qps", 0, "maximum queries per second to the API server (0 uses the client default)")
+	fs.IntVar(&c.burst, "burst", 0, "maximum burst of queries to the API server (0 uses the client default)")
+
+	fs.StringVar(&c.prometheusURL, "prometheus-url", "", "Prometheus endpoint of the cluster (default http://localhost:9090)")
+	fs.Var(&c.clusters, "cluster", "<context>=<prometheus-url> cluster to scan, can be repeated to scan several clusters concurrently")
+
+	fs.StringVar(&c.output, "o", defaultOutput, "output format")
+	return c
+}
+
+// withDryRun registers -dry-run for commands that change something.
+func (c *command) withDryRun(usage string) *command {
+	c.fs.BoolVar(&c.dryRun, "dry-run", false, usage)
+	return c
+}
+
+// parse parses the arguments and loads the configuration file overridden by
+// the flags given on the command line.
+func (c *command) parse(args []string) (config.Config, error) {
+	if err := c.fs.Parse(args); err != nil {
+		return config.Config{}, err
+	}
+	cfg, err := c.loadConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	return cfg, err
+}
+
+func (c *command) loadConfig() (config.Config, error) {
+	if c.fs.NArg() > 0 {
+		return config.Config{}, fmt.Errorf("unexpected arguments: %s", strings.Join(c.fs.Args(), " "))
+	}
+	cfg, err := config.Load(c.configPath)
+	if err != nil {
+		return config.Config{}, err
+	}
+
+	var flagErr error
+	c.fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "n", "namespaces":
+			cfg.Namespaces.Names = c.namespaces
+		case "all-namespaces":
+			cfg.Namespaces.All = c.allNamespaces
+		case "include-namespaces":
+			cfg.Namespaces.Include = c.includeNamespaces
+		case "exclude-namespaces":
+			cfg.Namespaces.Exclude = c.excludeNamespaces
+		case "namespace-selector":
+			cfg.Namespaces.Selector = c.namespaceSelector
+		case "include-system-namespaces":
+			cfg.Namespaces.IncludeSystem = c.includeSystemNamespaces
+		case "l":
+			cfg.Selector = c.selector
+		case "annotation-selector":
+			cfg.AnnotationSelector = c.annotationSelector
+		case "kubeconfig":
+			cfg.Kube.Kubeconfig = c.kubeconfig
+		case "context":
+			cfg.Kube.Context = c.kubeContext
+		case "as":
+			cfg.Kube.ImpersonateUser = c.impersonateUser
+		case "as-group":
+			cfg.Kube.ImpersonateGroups = c.impersonateGroups
+		case "qps":
+			cfg.Kube.QPS = float32(c.qps)
+		case "burst":
+			cfg.Kube.Burst = c.burst
+		case "prometheus-url":
+			cfg.Prometheus.URL = c.prometheusURL
+		case "cluster":
+			cfg.Clusters, flagErr = parseClusters(c.clusters)
+		}
+	})
+	if flagErr != nil {
+		return config.Config{}, flagErr
+	}
+	return cfg, validate(cfg)
+}
+
+// validate checks the configuration, including the report formats, which
+// only the report package knows.
+func validate(cfg config.Config) error {
+	errs := []error{cfg.Validate()}
+	for _, f := range cfg.Outputs.Formats {
+		if _, err := report.RendererFor(f); err != nil {
+			errs = append(errs, fmt.Errorf("config: outputs.formats: %v", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// parseClusters parses -cluster values of the form <context>=<prometheus-url>.
+func parseClusters(values []string) ([]config.ClusterConfig, error) {
+	var targets []config.ClusterConfig
+	for _, v := range values {
+		context, url, ok := strings.Cut(v, "=")
+		if !ok || context == "" || url == "" {
+			return nil, fmt.Errorf("invalid -cluster %q, expected <context>=<prometheus-url>", v)
+		}
+		targets = append(targets, config.ClusterConfig{
+			Name:       context,
+			Context:    context,
+			Prometheus: config.PrometheusConfig{URL: url},
+		})
+	}
+	return targets, nil
+}
+
+// usageStatus maps an error returned by parse to the exit status. Flag
+// errors are already printed by the flag set.
+func usageStatus(err error) int {
+	if errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	}
+	return exitUsage
+}
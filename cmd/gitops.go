@@ -0,0 +1,141 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/tabed23/k8s-resource-tuner/internal/gitops"
+	"github.com/tabed23/k8s-resource-tuner/internal/models"
+	"github.com/tabed23/k8s-resource-tuner/internal/report"
+)
+
+func runGitOps(args []string) int {
+	c := newCommand("gitops", "Rewrite the resources of the workloads in a local manifest repository and commit them to a new branch.", "table")
+	c.withDryRun("list the files that would change without writing or committing them")
+	repo := c.fs.String("repo", "", "path of the local manifest repository (overrides gitops.repo)")
+	branch := c.fs.String("branch", "", "branch to commit to (default <gitops.branchPrefix><run ID>)")
+	noCommit := c.fs.Bool("no-commit", false, "rewrite the files but do not create a branch and commit")
+	threshold := c.fs.Float64("threshold", 10, "only rewrite workloads with a change larger than this percentage of the current value")
+	cfg, err := c.parse(args)
+	if err != nil {
+		return usageStatus(err)
+	}
+	if *repo != "" {
+		cfg.GitOps.Repo = *repo
+	}
+	if cfg.GitOps.Repo == "" {
+		fmt.Fprintln(os.Stderr, "Error: -repo or gitops.repo is required")
+		return exitUsage
+	}
+	if c.output != "table" && c.output != "json" {
+		fmt.Fprintf(os.Stderr, "Error: unknown output format %q, use table or json\n", c.output)
+		return exitUsage
+	}
+	commit := !*noCommit && !c.dryRun
+	if commit {
+		if err := gitops.EnsureClean(cfg.GitOps.Repo); err != nil {
+			return fail(err)
+		}
+	}
+
+	var helm []gitops.HelmValues
+	for _, h := range cfg.GitOps.Helm {
+		helm = append(helm, gitops.HelmValues{Workload: h.Workload, File: h.Values, Containers: h.Containers})
+	}
+	manifests, err := gitops.Open(cfg.GitOps.Repo, helm)
+	if err != nil {
+		return fail(err)
+	}
+
+	clusters, connErr := connectAll(cfg)
+	reportData, scanErr := generateReport(cfg, clusters)
+	errs := []error{connErr, scanErr}
+	recordRun(cfg, "gitops", reportData, nil)
+
+	edits := []gitops.Edit{}
+	var changed []models.ReportEntry
+	seen := map[string]string{}
+	for _, e := range reportData.Entries {
+		if !report.NeedsChange(e, *threshold) {
+			continue
+		}
+		key := e.Workload.Kind + " " + e.Workload.Namespace + "/" + e.Workload.Name
+		if cluster, ok := seen[key]; ok {
+			// The repository holds one definition of the workload, the
+			// first cluster's recommendation wins.
+			fmt.Fprintf(os.Stderr, "Skipping %s of cluster %s, already rewritten from cluster %s\n", key, e.Cluster, cluster)
+			continue
+		}
+		seen[key] = e.Cluster
+		found, err := manifests.Rewrite(e)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", key, err))
+			continue
+		}
+		if len(found) == 0 {
+			fmt.Fprintf(os.Stderr, "No manifest found for %s\n", key)
+			continue
+		}
+		edits = append(edits, found...)
+		changed = append(changed, e)
+	}
+
+	if !c.dryRun && len(changed) > 0 {
+		files, saveErr := manifests.Save()
+		if saveErr != nil {
+			return fail(errors.Join(append(errs, saveErr)...))
+		}
+		if commit && len(files) > 0 {
+			name := *branch
+			if name == "" {
+				name = cfg.GitOps.BranchPrefix + reportData.RunID
+			}
+			if err := gitops.Commit(cfg.GitOps.Repo, name, commitMessage(reportData, changed, *threshold), files); err != nil {
+				return fail(errors.Join(append(errs, err)...))
+			}
+			fmt.Fprintf(os.Stderr, "Committed %d files to branch %s\n", len(files), name)
+		}
+	}
+
+	if c.output == "json" {
+		if encErr := writeJSON(edits); encErr != nil {
+			return fail(encErr)
+		}
+	} else {
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "CLUSTER\tNAMESPACE\tKIND\tNAME\tSOURCE\tFILE")
+		for _, ed := range edits {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", dash(ed.Cluster), ed.Namespace, ed.Kind, ed.Name, ed.Source, ed.File)
+		}
+		tw.Flush()
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return fail(err)
+	}
+	return exitOK
+}
+
+// commitMessage summarizes the rewritten values, one line per workload.
+func commitMessage(reportData models.Report, entries []models.ReportEntry, threshold float64) string {
+	var b strings.Builder
+	noun := "workloads"
+	if len(entries) == 1 {
+		noun = "workload"
+	}
+	fmt.Fprintf(&b, "Tune resources of %d %s\n\n", len(entries), noun)
+	fmt.Fprintf(&b, "Generated by k8s-resource-tuner from run %s over the last %s.\n\n", reportData.RunID, reportData.Lookback)
+	for _, e := range entries {
+		var values []string
+		for _, ch := range report.Compare(e) {
+			if ch.Exceeds(threshold) {
+				values = append(values, fmt.Sprintf("%s %s %s %s -> %s", ch.Container, ch.Resource, ch.Field, dash(ch.Current), dash(ch.Recommended)))
+			}
+		}
+		fmt.Fprintf(&b, "- %s %s/%s: %s\n", e.Workload.Kind, e.Workload.Namespace, e.Workload.Name, strings.Join(values, ", "))
+	}
+	return b.String()
+}
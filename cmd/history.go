@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/tabed23/k8s-resource-tuner/internal/config"
+	"github.com/tabed23/k8s-resource-tuner/internal/history"
+	"github.com/tabed23/k8s-resource-tuner/internal/models"
+	v1 "k8s.io/api/core/v1"
+)
+
+func runHistory(args []string) int {
+	c := newCommand("history", "List the recorded runs, follow the recommendations of a workload over time, or find flapping recommendations.", "table")
+	db := c.fs.String("db", "", "path of the history database (overrides history.path)")
+	since := c.fs.Duration("since", 0, "only show runs of this recent period, e.g. 336h (default all)")
+	workload := c.fs.String("workload", "", "show the recommendations of this workload over time, as <name> or <kind>/<name>")
+	flapping := c.fs.Bool("flapping", false, "list the containers whose recommended requests keep going up and down")
+	threshold := c.fs.Float64("threshold", 10, "with -flapping, ignore moves smaller than this percentage of the previous recommendation")
+	reversals := c.fs.Int("reversals", 2, "with -flapping, the changes of direction that make a recommendation flap")
+	cfg, err := c.parse(args)
+	if err != nil {
+		return usageStatus(err)
+	}
+	if *db != "" {
+		cfg.History.Path = *db
+	}
+	if cfg.History.Path == "" {
+		fmt.Fprintln(os.Stderr, "Error: -db or history.path is required")
+		return exitUsage
+	}
+	if *reversals < 1 {
+		fmt.Fprintln(os.Stderr, "Error: -reversals must be at least 1")
+		return exitUsage
+	}
+	if c.output != "table" && c.output != "json" {
+		fmt.Fprintf(os.Stderr, "Error: unknown output format %q, use table or json\n", c.output)
+		return exitUsage
+	}
+
+	store, err := history.Open(cfg.History.Path)
+	if err != nil {
+		return fail(err)
+	}
+	defer store.Close()
+	var from time.Time
+	if *since > 0 {
+		from = time.Now().Add(-*since)
+	}
+
+	if *workload == "" && !*flapping {
+		runs, err := store.Runs(from)
+		if err != nil {
+			return fail(err)
+		}
+		if c.output == "json" {
+			return writeOrFail(runs)
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "RUN\tTIME\tCOMMAND\tLOOKBACK\tCLUSTERS\tWORKLOADS\tAPPLIED")
+		for _, r := range runs {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%d\t%d\n", r.ID, r.Timestamp.Local().Format(time.DateTime),
+				r.Command, dash(r.Lookback), dash(strings.Join(r.Clusters, ",")), r.Workloads, len(r.Applied))
+		}
+		tw.Flush()
+		return exitOK
+	}
+
+	filter := history.Filter{Since: from, Namespaces: c.namespaces}
+	if kind, name, ok := strings.Cut(*workload, "/"); ok {
+		filter.Kind, filter.Workload = kind, name
+	} else {
+		filter.Workload = *workload
+	}
+	records, err := store.History(filter)
+	if err != nil {
+		return fail(err)
+	}
+
+	if *flapping {
+		flaps := history.Flapping(records, *threshold, *reversals)
+		if flaps == nil {
+			flaps = []history.Flap{}
+		}
+		if c.output == "json" {
+			return writeOrFail(flaps)
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "CLUSTER\tNAMESPACE\tKIND\tNAME\tCONTAINER\tRESOURCE\tREVERSALS\tRECOMMENDED")
+		for _, f := range flaps {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%d\t%s\n", dash(f.Cluster), f.Namespace, f.Kind, f.Workload,
+				f.Container, f.Resource, f.Reversals, strings.Join(f.Values, " -> "))
+		}
+		tw.Flush()
+		return exitOK
+	}
+
+	if records == nil {
+		records = []history.Record{}
+	}
+	if c.output == "json" {
+		return writeOrFail(records)
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "RUN\tCLUSTER\tNAMESPACE\tKIND\tNAME\tCONTAINER\tCPU REQUEST\tMEMORY REQUEST\tCPU P95\tMEMORY P95\tAPPLIED")
+	for _, r := range records {
+		cpuP95, memP95 := "-", "-"
+		if r.Usage != nil {
+			cpuP95 = fmt.Sprintf("%dm", int64(math.Round(r.Usage.CPUP95*1000)))
+			memP95 = fmt.Sprintf("%dMi", int64(math.Round(r.Usage.MemP95/(1024*1024))))
+		}
+		applied := ""
+		if r.Patched != nil {
+			applied = quantity(r.Patched.Request, v1.ResourceCPU) + "/" + quantity(r.Patched.Request, v1.ResourceMemory)
+		} else if r.Applied {
+			applied = "yes"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s -> %s\t%s -> %s\t%s\t%s\t%s\n",
+			r.RunID, dash(r.Cluster), r.Namespace, r.Kind, r.Workload, r.Container,
+			quantity(r.Current.Request, v1.ResourceCPU), quantity(r.Recommended.Request, v1.ResourceCPU),
+			quantity(r.Current.Request, v1.ResourceMemory), quantity(r.Recommended.Request, v1.ResourceMemory),
+			cpuP95, memP95, dash(applied))
+	}
+	tw.Flush()
+	return exitOK
+}
+
+func writeOrFail(v interface{}) int {
+	if err := writeJSON(v); err != nil {
+		return fail(err)
+	}
+	return exitOK
+}
+
+// recordRun saves the report in the history database when one is
+// configured, along with the entries apply patched as they were applied,
+// and deletes the runs past the retention. A failure to record is only a
+// warning since the run itself went through.
+func recordRun(cfg config.Config, command string, reportData models.Report, patched []models.ReportEntry) {
+	if cfg.History.Path == "" {
+		return
+	}
+	run := history.Run{
+		ID:        reportData.RunID,
+		Timestamp: reportData.Timestamp,
+		Command:   command,
+		Lookback:  reportData.Lookback,
+		Clusters:  reportData.Clusters,
+	}
+	store, err := history.Open(cfg.History.Path)
+	if err == nil {
+		err = store.Save(run, reportData, patched)
+		if err == nil && cfg.History.Retention > 0 {
+			_, err = store.Prune(time.Now().Add(-cfg.History.Retention))
+		}
+		store.Close()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+}
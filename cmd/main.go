@@ -2,56 +2,58 @@ package main
 
 import (
 	"fmt"
-	"time"
-
-	"github.com/tabed23/k8s-resource-tuner/internal/k8s"
-	"github.com/tabed23/k8s-resource-tuner/internal/models"
-	"github.com/tabed23/k8s-resource-tuner/internal/notifier"
-	"github.com/tabed23/k8s-resource-tuner/internal/prometheus"
-	"github.com/tabed23/k8s-resource-tuner/internal/report"
+	"os"
 )
 
+// Exit statuses, stable so CI pipelines can branch on them.
 const (
-	slackToken   = ""
-	slackChannel = ""
-)
-
-var (
-	namespaces = []string{"test"}
+	exitOK      = 0
+	exitError   = 1 // the run failed, or some clusters/namespaces failed
+	exitUsage   = 2 // invalid flags or configuration
+	exitChanges = 3 // diff found recommendations beyond the threshold
 )
 
-func main() {
-	clientSet, err := k8s.InitKubeClient()
-	if err != nil {
-		panic(err)
-	}
-	prom := prometheus.NewPromClient("http://localhost:9090")
-	var allEntries []models.ReportEntry
-	var allSummaries string
+var commands = []struct {
+	name    string
+	summary string
+	run     func(args []string) int
+}{
+	{"scan", "list workloads and their current resources", runScan},
+	{"recommend", "compute and print recommendations", runRecommend},
+	{"report", "render recommendations to report files", runReport},
+	{"diff", "compare current resources with recommendations", runDiff},
+	{"apply", "patch workloads with recommended resources", runApply},
+	{"rollback", "restore the resources recorded by apply", runRollback},
+	{"gitops", "rewrite resources in a local manifest repository", runGitOps},
+	{"history", "list recorded runs and how recommendations evolve", runHistory},
+	{"compare", "compare two recorded runs or JSON reports", runCompare},
+}
 
-	for _, ns := range namespaces {
-		reportData, err := report.GenrateReport(clientSet, prom, ns)
-		if err != nil {
-			fmt.Printf("Error generating report for %s: %v\n", ns, err)
-			continue
-		}
-		allEntries = append(allEntries, reportData.Entries...)
-		allSummaries += fmt.Sprintf("Namespace: %s\n%s\n", ns, reportData.Summary)
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: k8s-resource-tuner <command> [flags]")
+	fmt.Fprintln(os.Stderr, "\nCommands:")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", c.name, c.summary)
 	}
+	fmt.Fprintln(os.Stderr, "\nRun 'k8s-resource-tuner <command> -h' for the flags of a command.")
+}
 
-	combinedReport := models.Report{
-		Timestamp: time.Now(),
-		Entries:   allEntries,
-		Summary:   allSummaries,
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(exitUsage)
 	}
-
-	reportPDF, err := report.PDFReport(combinedReport, "ALL_NAMESPACES")
-	if err != nil {
-		panic(err)
+	name := os.Args[1]
+	if name == "-h" || name == "-help" || name == "--help" || name == "help" {
+		usage()
+		os.Exit(exitOK)
 	}
-	fmt.Printf("Combined report generated successfully: %s\n", reportPDF)
-	if err := notifier.SendReportToSlack(slackToken, slackChannel, reportPDF); err != nil {
-		panic(err)
+	for _, c := range commands {
+		if c.name == name {
+			os.Exit(c.run(os.Args[2:]))
+		}
 	}
-
+	fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", name)
+	usage()
+	os.Exit(exitUsage)
 }
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/tabed23/k8s-resource-tuner/internal/models"
+	"github.com/tabed23/k8s-resource-tuner/internal/patch"
+	"github.com/tabed23/k8s-resource-tuner/internal/report"
+	"github.com/tabed23/k8s-resource-tuner/internal/vpa"
+	v1 "k8s.io/api/core/v1"
+)
+
+func runRecommend(args []string) int {
+	c := newCommand("recommend", "Compute recommendations from Prometheus usage and print them.", "table")
+	patchDir := c.fs.String("patch-dir", "", "write strategic merge patches, JSON patches and kubectl commands to this directory")
+	threshold := c.fs.Float64("threshold", 10, "only write patches for workloads with a change larger than this percentage of the current value")
+	vpaDir := c.fs.String("vpa-dir", "", "write a VerticalPodAutoscaler manifest per workload to this directory")
+	vpaMode := c.fs.String("vpa-mode", "", "update mode of the VerticalPodAutoscaler manifests, Off or Initial (default Off)")
+	compareVPA := c.fs.Bool("compare-vpa", false, "show the target of existing VerticalPodAutoscalers next to the recommendations")
+	cfg, err := c.parse(args)
+	if err != nil {
+		return usageStatus(err)
+	}
+	if *patchDir != "" {
+		cfg.Outputs.PatchDir = *patchDir
+	}
+	if *vpaDir != "" {
+		cfg.Outputs.VPADir = *vpaDir
+	}
+	if *vpaMode != "" {
+		cfg.Outputs.VPAUpdateMode = *vpaMode
+	}
+	if *compareVPA {
+		cfg.Outputs.CompareVPA = true
+	}
+	mode, err := vpa.ParseUpdateMode(cfg.Outputs.VPAUpdateMode)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitUsage
+	}
+	if c.output != "table" && c.output != "json" {
+		fmt.Fprintf(os.Stderr, "Error: unknown output format %q, use table or json\n", c.output)
+		return exitUsage
+	}
+
+	clusters, connErr := connectAll(cfg)
+	reportData, err := generateReport(cfg, clusters)
+	err = errors.Join(connErr, err)
+	recordRun(cfg, "recommend", reportData, nil)
+	if c.output == "json" {
+		if encErr := writeJSON(reportData); encErr != nil {
+			return fail(encErr)
+		}
+	} else {
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		header := "CLUSTER\tNAMESPACE\tKIND\tNAME\tCONTAINER\tCPU REQ\tCPU LIM\tMEM REQ\tMEM LIM"
+		if cfg.Outputs.CompareVPA {
+			header += "\tVPA CPU\tVPA MEM"
+		}
+		fmt.Fprintln(tw, header)
+		for _, e := range reportData.Entries {
+			for _, rec := range e.Recommendation {
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s",
+					dash(e.Cluster), e.Workload.Namespace, e.Workload.Kind, e.Workload.Name, rec.ContainerName,
+					quantity(rec.RecommendedRequest.Request, v1.ResourceCPU), quantity(rec.RecommendedLimit.Limits, v1.ResourceCPU),
+					quantity(rec.RecommendedRequest.Request, v1.ResourceMemory), quantity(rec.RecommendedLimit.Limits, v1.ResourceMemory))
+				if cfg.Outputs.CompareVPA {
+					fmt.Fprintf(tw, "\t%s\t%s", quantity(rec.VPATarget, v1.ResourceCPU), quantity(rec.VPATarget, v1.ResourceMemory))
+				}
+				fmt.Fprintln(tw)
+			}
+		}
+		tw.Flush()
+	}
+
+	if cfg.Outputs.PatchDir != "" {
+		var changed []models.ReportEntry
+		for _, e := range reportData.Entries {
+			if report.NeedsChange(e, *threshold) {
+				changed = append(changed, e)
+			}
+		}
+		written, patchErr := patch.WriteFiles(cfg.Outputs.PatchDir, changed, kubeTargets(cfg))
+		if patchErr != nil {
+			return fail(patchErr)
+		}
+		fmt.Fprintf(os.Stderr, "Wrote %d patch files to %s\n", len(written), cfg.Outputs.PatchDir)
+	}
+
+	if cfg.Outputs.VPADir != "" {
+		policies, policyErr := cfg.PolicySet()
+		if policyErr != nil {
+			return fail(policyErr)
+		}
+		written, vpaErr := vpa.WriteFiles(cfg.Outputs.VPADir, reportData.Entries, policies, mode)
+		if vpaErr != nil {
+			return fail(vpaErr)
+		}
+		fmt.Fprintf(os.Stderr, "Wrote %d VerticalPodAutoscaler manifests to %s\n", len(written), cfg.Outputs.VPADir)
+	}
+
+	if err != nil {
+		return fail(err)
+	}
+	return exitOK
+}
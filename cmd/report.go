@@ -0,0 +1,62 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/tabed23/k8s-resource-tuner/internal/notifier"
+	"github.com/tabed23/k8s-resource-tuner/internal/report"
+)
+
+func runReport(args []string) int {
+	c := newCommand("report", "Render recommendations to report files and send them to the configured notifiers.", "")
+	c.fs.Lookup("o").Usage = "comma-separated report formats: " + strings.Join(report.Formats(), ", ") + " (default from config, pdf)"
+	c.withDryRun("render the reports without sending notifications")
+	cfg, err := c.parse(args)
+	if err != nil {
+		return usageStatus(err)
+	}
+	if c.output != "" {
+		var formats listFlag
+		formats.Set(c.output)
+		cfg.Outputs.Formats = formats
+		if err := validate(cfg); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitUsage
+		}
+	}
+
+	clusters, connErr := connectAll(cfg)
+	reportData, scanErr := generateReport(cfg, clusters)
+	scanErr = errors.Join(connErr, scanErr)
+	recordRun(cfg, "report", reportData, nil)
+
+	var reportPDF string
+	for _, format := range cfg.Outputs.Formats {
+		renderer, err := report.RendererFor(format)
+		if err != nil {
+			return fail(err)
+		}
+		file, err := report.WriteReport(renderer, reportData, "ALL_NAMESPACES")
+		if err != nil {
+			return fail(err)
+		}
+		if format == "pdf" {
+			reportPDF = file
+		}
+	}
+
+	slack := cfg.Notifiers.Slack
+	if slack.Token != "" && reportPDF != "" && !c.dryRun {
+		if err := notifier.SendReportToSlack(slack.Token, slack.Channel, reportPDF); err != nil {
+			return fail(err)
+		}
+	}
+
+	if scanErr != nil {
+		return fail(scanErr)
+	}
+	return exitOK
+}
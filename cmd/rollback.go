@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/tabed23/k8s-resource-tuner/internal/apply"
+	"github.com/tabed23/k8s-resource-tuner/internal/k8s"
+	"github.com/tabed23/k8s-resource-tuner/internal/models"
+)
+
+func runRollback(args []string) int {
+	c := newCommand("rollback", "Restore the resources workloads had before apply patched them.", "table")
+	c.withDryRun("validate the rollback patches with a server-side dry run without persisting them")
+	workload := c.fs.String("workload", "", "only roll back this workload, as <name> or <kind>/<name>")
+	run := c.fs.String("run", "", "only roll back workloads patched by this apply run ID")
+	cfg, err := c.parse(args)
+	if err != nil {
+		return usageStatus(err)
+	}
+	if c.output != "table" && c.output != "json" {
+		fmt.Fprintf(os.Stderr, "Error: unknown output format %q, use table or json\n", c.output)
+		return exitUsage
+	}
+
+	var mu sync.Mutex
+	results := []apply.Result{}
+	clusters, connErr := connectAll(cfg)
+	err = forEachCluster(clusters, func(cl *cluster) error {
+		var errs []error
+		for _, ns := range cl.namespaces {
+			list, err := k8s.ListWorkloads(cl.clientset, ns, cfg.WorkloadFilter())
+			if err != nil {
+				errs = append(errs, fmt.Errorf("namespace %s: %v", ns, err))
+				continue
+			}
+			for _, w := range list {
+				if !rollbackSelected(w, *workload, *run) {
+					continue
+				}
+				result := apply.Rollback(cl.clientset, cl.name, w, c.dryRun)
+				if result.Status == "failed" {
+					errs = append(errs, errors.New(result.Message))
+				}
+				mu.Lock()
+				results = append(results, result)
+				mu.Unlock()
+			}
+		}
+		return errors.Join(errs...)
+	})
+	err = errors.Join(connErr, err)
+	sort.SliceStable(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.Cluster != b.Cluster {
+			return a.Cluster < b.Cluster
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Kind+a.Name < b.Kind+b.Name
+	})
+
+	if c.output == "json" {
+		if encErr := writeJSON(results); encErr != nil {
+			return fail(encErr)
+		}
+	} else {
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "CLUSTER\tNAMESPACE\tKIND\tNAME\tSTATUS\tMESSAGE")
+		for _, r := range results {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", dash(r.Cluster), r.Namespace, r.Kind, r.Name, r.Status, r.Message)
+		}
+		tw.Flush()
+	}
+
+	if err != nil {
+		return fail(err)
+	}
+	return exitOK
+}
+
+// rollbackSelected reports whether the workload was patched by apply and
+// matches the -workload and -run filters.
+func rollbackSelected(w models.WorkLoad, workload, run string) bool {
+	if _, ok := w.Annotations[models.AnnotationPrevious]; !ok {
+		return false
+	}
+	if run != "" && w.Annotations[models.AnnotationApplyRun] != run {
+		return false
+	}
+	if workload == "" {
+		return true
+	}
+	if kind, name, ok := strings.Cut(workload, "/"); ok {
+		return strings.EqualFold(kind, w.Kind) && name == w.Name
+	}
+	return workload == w.Name
+}
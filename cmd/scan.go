@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/tabed23/k8s-resource-tuner/internal/helper"
+	"github.com/tabed23/k8s-resource-tuner/internal/k8s"
+	"github.com/tabed23/k8s-resource-tuner/internal/models"
+	v1 "k8s.io/api/core/v1"
+)
+
+// scannedWorkload is a workload tagged with the cluster it was found in.
+type scannedWorkload struct {
+	Cluster string `json:"cluster,omitempty"`
+	models.WorkLoad
+}
+
+func runScan(args []string) int {
+	c := newCommand("scan", "List workloads and the resources they currently request.", "table")
+	cfg, err := c.parse(args)
+	if err != nil {
+		return usageStatus(err)
+	}
+	if c.output != "table" && c.output != "json" {
+		fmt.Fprintf(os.Stderr, "Error: unknown output format %q, use table or json\n", c.output)
+		return exitUsage
+	}
+
+	var mu sync.Mutex
+	var workloads []scannedWorkload
+	clusters, connErr := connectAll(cfg)
+	err = forEachCluster(clusters, func(cl *cluster) error {
+		for _, ns := range cl.namespaces {
+			list, err := k8s.ListWorkloads(cl.clientset, ns, cfg.WorkloadFilter())
+			if err != nil {
+				return fmt.Errorf("namespace %s: %v", ns, err)
+			}
+			mu.Lock()
+			for _, w := range list {
+				workloads = append(workloads, scannedWorkload{Cluster: cl.name, WorkLoad: w})
+			}
+			mu.Unlock()
+		}
+		return nil
+	})
+	err = errors.Join(connErr, err)
+	sort.SliceStable(workloads, func(i, j int) bool {
+		a, b := workloads[i], workloads[j]
+		if a.Cluster != b.Cluster {
+			return a.Cluster < b.Cluster
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Kind+a.Name < b.Kind+b.Name
+	})
+
+	if c.output == "json" {
+		if encErr := writeJSON(workloads); encErr != nil {
+			return fail(encErr)
+		}
+	} else {
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "CLUSTER\tNAMESPACE\tKIND\tNAME\tCONTAINER\tROLE\tCPU REQ\tCPU LIM\tMEM REQ\tMEM LIM\tNOTE")
+		for _, w := range workloads {
+			note := ""
+			if w.Ignored() {
+				note = "ignored"
+			} else if p := w.Annotations[models.AnnotationPolicy]; p != "" {
+				note = "policy=" + p
+			}
+			for _, ct := range w.Containers {
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+					dash(w.Cluster), w.Namespace, w.Kind, w.Name, ct.Name, ct.Role,
+					quantity(ct.Resources.Request, v1.ResourceCPU), quantity(ct.Resources.Limits, v1.ResourceCPU),
+					quantity(ct.Resources.Request, v1.ResourceMemory), quantity(ct.Resources.Limits, v1.ResourceMemory),
+					note)
+			}
+		}
+		tw.Flush()
+	}
+
+	if err != nil {
+		return fail(err)
+	}
+	return exitOK
+}
+
+func writeJSON(v interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// quantity formats one resource of a list, "-" when it is not set.
+func quantity(rl v1.ResourceList, name v1.ResourceName) string {
+	q, ok := rl[name]
+	if !ok {
+		return "-"
+	}
+	return helper.QuantityToString(q)
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func fail(err error) int {
+	fmt.Fprintln(os.Stderr, "Error:", err)
+	return exitError
+}
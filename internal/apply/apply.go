@@ -0,0 +1,240 @@
+package apply
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/tabed23/k8s-resource-tuner/internal/k8s"
+	"github.com/tabed23/k8s-resource-tuner/internal/models"
+	"github.com/tabed23/k8s-resource-tuner/internal/patch"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Options are the safety gates of an apply run.
+type Options struct {
+	// DryRun validates the patches on the API server without persisting them.
+	DryRun bool
+	// RunID is recorded on the patched workloads so that a whole run can be
+	// rolled back.
+	RunID string
+	// AllowedNamespaces are the glob or re:<regex> patterns of the namespaces
+	// apply may patch. Nothing is approved when the list is empty.
+	AllowedNamespaces []string
+	// MaxChangePercent caps how far a value moves away from the current one
+	// in a single run. Zero disables the cap.
+	MaxChangePercent float64
+	// ProtectMemory keeps memory requests and limits at or above the highest
+	// memory usage observed over the lookback window.
+	ProtectMemory bool
+}
+
+// Result records what apply did to one workload.
+type Result struct {
+	Cluster   string `json:"cluster,omitempty"`
+	Namespace string `json:"namespace"`
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Status    string `json:"status"` // patched, rolled-back, dry-run, skipped or failed
+	Message   string `json:"message,omitempty"`
+}
+
+// Approved reports whether the namespace is in the allowlist.
+func (o Options) Approved(namespace string) (bool, error) {
+	if len(o.AllowedNamespaces) == 0 {
+		return false, nil
+	}
+	filter := k8s.NamespaceFilter{Include: o.AllowedNamespaces, IncludeSystem: true}
+	return filter.Matches(namespace)
+}
+
+// Guard returns the entry with its recommendations limited by the change
+// cap and the memory guard, along with a note for every adjustment. A
+// resource without usage samples is never lowered, there is no evidence
+// that the workload needs less.
+func Guard(e models.ReportEntry, opts Options) (models.ReportEntry, []string) {
+	var notes []string
+	recs := make([]models.Recommendation, 0, len(e.Recommendation))
+	for _, rec := range e.Recommendation {
+		current := currentResources(e.Workload, rec.ContainerName)
+		req := copyList(rec.RecommendedRequest.Request)
+		lim := copyList(rec.RecommendedLimit.Limits)
+		for _, name := range []v1.ResourceName{v1.ResourceCPU, v1.ResourceMemory} {
+			if len(samples(rec, name)) == 0 {
+				if q, ok := current.Request[name]; ok && raise(req, name, q) {
+					notes = append(notes, fmt.Sprintf("%s %s request kept at %s, no usage observed", rec.ContainerName, name, q.String()))
+				}
+				if q, ok := current.Limits[name]; ok && raise(lim, name, q) {
+					notes = append(notes, fmt.Sprintf("%s %s limit kept at %s, no usage observed", rec.ContainerName, name, q.String()))
+				}
+			}
+			if opts.MaxChangePercent > 0 {
+				if clampChange(req, current.Request, name, opts.MaxChangePercent) {
+					notes = append(notes, fmt.Sprintf("%s %s request capped at %.0f%% change", rec.ContainerName, name, opts.MaxChangePercent))
+				}
+				if clampChange(lim, current.Limits, name, opts.MaxChangePercent) {
+					notes = append(notes, fmt.Sprintf("%s %s limit capped at %.0f%% change", rec.ContainerName, name, opts.MaxChangePercent))
+				}
+			}
+		}
+		if opts.ProtectMemory && rec.UsageStats != nil {
+			if floor, ok := observedMaxMemory(rec.UsageStats.MemSamples); ok {
+				if raise(req, v1.ResourceMemory, floor) {
+					notes = append(notes, fmt.Sprintf("%s memory request raised to observed max %s", rec.ContainerName, floor.String()))
+				}
+				if raise(lim, v1.ResourceMemory, floor) {
+					notes = append(notes, fmt.Sprintf("%s memory limit raised to observed max %s", rec.ContainerName, floor.String()))
+				}
+			}
+		}
+		for _, name := range []v1.ResourceName{v1.ResourceCPU, v1.ResourceMemory} {
+			// Capping request and limit independently can leave the limit
+			// below the request, which the API server rejects.
+			if r, ok := req[name]; ok && raise(lim, name, r) {
+				notes = append(notes, fmt.Sprintf("%s %s limit raised to the request", rec.ContainerName, name))
+			}
+		}
+		rec.RecommendedRequest.Request = req
+		rec.RecommendedLimit.Limits = lim
+		recs = append(recs, rec)
+	}
+	e.Recommendation = recs
+	return e, notes
+}
+
+// Apply patches the workload of the entry with its recommendations and
+// records the previous resources, the time and the run ID in annotations.
+// The entry is expected to have gone through Guard already.
+func Apply(clientset *kubernetes.Clientset, e models.ReportEntry, opts Options) Result {
+	w := e.Workload
+	result := Result{Cluster: e.Cluster, Namespace: w.Namespace, Kind: w.Kind, Name: w.Name}
+	if w.Kind == models.KindJob {
+		result.Status, result.Message = "skipped", "the pod template of a Job is immutable"
+		return result
+	}
+	approved, err := opts.Approved(w.Namespace)
+	if err != nil {
+		result.Status, result.Message = "failed", err.Error()
+		return result
+	}
+	if !approved && !opts.DryRun {
+		result.Status, result.Message = "skipped", "namespace is not in the apply allowlist"
+		return result
+	}
+
+	strategic, err := patch.StrategicMerge(w, e.Recommendation)
+	if err == nil {
+		var previous string
+		if previous, err = PreviousResources(w, e.Recommendation); err == nil {
+			strategic, err = patch.Annotate(strategic, map[string]string{
+				models.AnnotationPrevious:  previous,
+				models.AnnotationAppliedAt: time.Now().UTC().Format(time.RFC3339),
+				models.AnnotationApplyRun:  opts.RunID,
+			})
+		}
+	}
+	if err == nil {
+		err = k8s.PatchResources(clientset, w, strategic, opts.DryRun)
+	}
+	switch {
+	case err != nil:
+		result.Status, result.Message = "failed", err.Error()
+	case opts.DryRun:
+		result.Status = "dry-run"
+		if !approved {
+			result.Message = "namespace is not in the apply allowlist"
+		}
+	default:
+		result.Status = "patched"
+	}
+	return result
+}
+
+// PreviousResources encodes the current resources of the recommended
+// containers as the value of the AnnotationPrevious annotation.
+func PreviousResources(w models.WorkLoad, recs []models.Recommendation) (string, error) {
+	previous := map[string]models.ResourceConfig{}
+	for _, rec := range recs {
+		previous[rec.ContainerName] = currentResources(w, rec.ContainerName)
+	}
+	data, err := json.Marshal(previous)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode previous resources: %v", err)
+	}
+	return string(data), nil
+}
+
+func currentResources(w models.WorkLoad, container string) models.ResourceConfig {
+	for _, c := range w.Containers {
+		if c.Name == container {
+			return c.Resources
+		}
+	}
+	return models.ResourceConfig{}
+}
+
+// clampChange keeps the recommended value within maxPercent of the current
+// one. Added or removed values are left alone.
+func clampChange(recommended, current v1.ResourceList, name v1.ResourceName, maxPercent float64) bool {
+	rec, ok := recommended[name]
+	cur, set := current[name]
+	if !ok || !set || cur.IsZero() {
+		return false
+	}
+	value, base := rec.AsApproximateFloat64(), cur.AsApproximateFloat64()
+	low, high := base*(1-maxPercent/100), base*(1+maxPercent/100)
+	if value >= low && value <= high {
+		return false
+	}
+	clamped := math.Max(low, math.Min(high, value))
+	if name == v1.ResourceCPU {
+		recommended[name] = *resource.NewMilliQuantity(int64(math.Round(clamped*1000)), resource.DecimalSI)
+	} else {
+		recommended[name] = *resource.NewQuantity(int64(math.Round(clamped)), resource.BinarySI)
+	}
+	return true
+}
+
+// samples returns the usage samples of one resource of the recommendation.
+func samples(rec models.Recommendation, name v1.ResourceName) []float64 {
+	if rec.UsageStats == nil {
+		return nil
+	}
+	if name == v1.ResourceCPU {
+		return rec.UsageStats.CPUSamples
+	}
+	return rec.UsageStats.MemSamples
+}
+
+// observedMaxMemory returns the highest memory sample rounded up to a MiB.
+func observedMaxMemory(samples []float64) (resource.Quantity, bool) {
+	max := 0.0
+	for _, v := range samples {
+		max = math.Max(max, v)
+	}
+	if max <= 0 {
+		return resource.Quantity{}, false
+	}
+	const mib = 1024 * 1024
+	return *resource.NewQuantity(int64(math.Ceil(max/mib))*mib, resource.BinarySI), true
+}
+
+// raise sets the value of a present entry to floor when it is lower.
+func raise(list v1.ResourceList, name v1.ResourceName, floor resource.Quantity) bool {
+	q, ok := list[name]
+	if !ok || q.Cmp(floor) >= 0 {
+		return false
+	}
+	list[name] = floor
+	return true
+}
+
+func copyList(list v1.ResourceList) v1.ResourceList {
+	if list == nil {
+		return nil
+	}
+	return list.DeepCopy()
+}
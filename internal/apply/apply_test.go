@@ -0,0 +1,165 @@
+package apply
+
+import (
+	"testing"
+
+	"github.com/tabed23/k8s-resource-tuner/internal/models"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func list(values ...string) v1.ResourceList {
+	l := v1.ResourceList{}
+	for i := 0; i+1 < len(values); i += 2 {
+		l[v1.ResourceName(values[i])] = resource.MustParse(values[i+1])
+	}
+	return l
+}
+
+func TestClampChange(t *testing.T) {
+	tests := []struct {
+		name        string
+		recommended v1.ResourceList
+		current     v1.ResourceList
+		resource    v1.ResourceName
+		want        string
+		clamped     bool
+	}{
+		{"within cap", list("cpu", "400m"), list("cpu", "500m"), v1.ResourceCPU, "400m", false},
+		{"capped down", list("cpu", "100m"), list("cpu", "1"), v1.ResourceCPU, "500m", true},
+		{"capped up", list("cpu", "2"), list("cpu", "1"), v1.ResourceCPU, "1500m", true},
+		{"memory capped down", list("memory", "64Mi"), list("memory", "1Gi"), v1.ResourceMemory, "512Mi", true},
+		{"added value", list("cpu", "100m"), list(), v1.ResourceCPU, "100m", false},
+		{"zero current", list("cpu", "100m"), list("cpu", "0"), v1.ResourceCPU, "100m", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clamped := clampChange(tt.recommended, tt.current, tt.resource, 50)
+			if clamped != tt.clamped {
+				t.Errorf("clamped = %v, want %v", clamped, tt.clamped)
+			}
+			got := tt.recommended[tt.resource]
+			if want := resource.MustParse(tt.want); got.Cmp(want) != 0 {
+				t.Errorf("value = %s, want %s", got.String(), tt.want)
+			}
+		})
+	}
+	if clampChange(list(), list("cpu", "1"), v1.ResourceCPU, 50) {
+		t.Error("a removed value must not be clamped")
+	}
+}
+
+func entry(current models.ResourceConfig, rec models.Recommendation) models.ReportEntry {
+	rec.ContainerName = "app"
+	return models.ReportEntry{
+		Workload: models.WorkLoad{
+			Namespace:  "shop",
+			Name:       "api",
+			Kind:       models.KindDeployment,
+			Containers: []models.ContainerSpec{{Name: "app", Role: models.RoleApp, Resources: current}},
+		},
+		Recommendation: []models.Recommendation{rec},
+	}
+}
+
+func TestGuard(t *testing.T) {
+	const mib = 1024 * 1024
+	usage := &models.UsageStats{CPUSamples: []float64{0.1, 0.2}, MemSamples: []float64{100 * mib, 300 * mib}}
+	tests := []struct {
+		name    string
+		opts    Options
+		current models.ResourceConfig
+		rec     models.Recommendation
+		wantReq v1.ResourceList
+		wantLim v1.ResourceList
+		notes   int
+	}{
+		{
+			name:    "change cap",
+			opts:    Options{MaxChangePercent: 50},
+			current: models.ResourceConfig{Request: list("cpu", "1", "memory", "1Gi")},
+			rec: models.Recommendation{
+				RecommendedRequest: models.ResourceConfig{Request: list("cpu", "200m", "memory", "800Mi")},
+				UsageStats:         usage,
+			},
+			wantReq: list("cpu", "500m", "memory", "800Mi"),
+			notes:   1,
+		},
+		{
+			name:    "memory raised to observed max",
+			opts:    Options{ProtectMemory: true},
+			current: models.ResourceConfig{Request: list("cpu", "1", "memory", "1Gi"), Limits: list("memory", "1Gi")},
+			rec: models.Recommendation{
+				RecommendedRequest: models.ResourceConfig{Request: list("cpu", "200m", "memory", "128Mi")},
+				RecommendedLimit:   models.ResourceConfig{Limits: list("memory", "256Mi")},
+				UsageStats:         usage,
+			},
+			wantReq: list("cpu", "200m", "memory", "300Mi"),
+			wantLim: list("memory", "300Mi"),
+			notes:   2,
+		},
+		{
+			name:    "limit raised to capped request",
+			opts:    Options{MaxChangePercent: 50},
+			current: models.ResourceConfig{Request: list("cpu", "1"), Limits: list("cpu", "4")},
+			rec: models.Recommendation{
+				RecommendedRequest: models.ResourceConfig{Request: list("cpu", "3")},
+				RecommendedLimit:   models.ResourceConfig{Limits: list("cpu", "1")},
+				UsageStats:         usage,
+			},
+			wantReq: list("cpu", "1500m"),
+			wantLim: list("cpu", "2"),
+			notes:   2,
+		},
+		{
+			name:    "no samples keeps current values",
+			opts:    Options{MaxChangePercent: 50, ProtectMemory: true},
+			current: models.ResourceConfig{Request: list("cpu", "1", "memory", "1Gi"), Limits: list("memory", "2Gi")},
+			rec: models.Recommendation{
+				RecommendedRequest: models.ResourceConfig{Request: list("cpu", "5m", "memory", "16Mi")},
+				RecommendedLimit:   models.ResourceConfig{Limits: list("memory", "16Mi")},
+				UsageStats:         &models.UsageStats{},
+			},
+			wantReq: list("cpu", "1", "memory", "1Gi"),
+			wantLim: list("memory", "2Gi"),
+			notes:   3,
+		},
+		{
+			name:    "no samples still allows raising",
+			current: models.ResourceConfig{Request: list("cpu", "100m")},
+			rec: models.Recommendation{
+				RecommendedRequest: models.ResourceConfig{Request: list("cpu", "200m")},
+			},
+			wantReq: list("cpu", "200m"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := entry(tt.current, tt.rec)
+			guarded, notes := Guard(e, tt.opts)
+			got := guarded.Recommendation[0]
+			assertList(t, "request", got.RecommendedRequest.Request, tt.wantReq)
+			assertList(t, "limit", got.RecommendedLimit.Limits, tt.wantLim)
+			if len(notes) != tt.notes {
+				t.Errorf("notes = %q, want %d", notes, tt.notes)
+			}
+			// The input entry is left untouched.
+			if q := e.Recommendation[0].RecommendedRequest.Request[v1.ResourceCPU]; q.Cmp(tt.rec.RecommendedRequest.Request[v1.ResourceCPU]) != 0 {
+				t.Errorf("input recommendation modified to %s", q.String())
+			}
+		})
+	}
+}
+
+func assertList(t *testing.T, field string, got, want v1.ResourceList) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Errorf("%s = %v, want %v", field, got, want)
+		return
+	}
+	for name, w := range want {
+		if g, ok := got[name]; !ok || g.Cmp(w) != 0 {
+			t.Errorf("%s %s = %s, want %s", field, name, g.String(), w.String())
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package apply
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/tabed23/k8s-resource-tuner/internal/k8s"
+	"github.com/tabed23/k8s-resource-tuner/internal/models"
+	"github.com/tabed23/k8s-resource-tuner/internal/patch"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Previous decodes the resources recorded by Apply on the workload. ok is
+// false when the workload was never patched by the tuner.
+func Previous(w models.WorkLoad) (previous map[string]models.ResourceConfig, ok bool, err error) {
+	value, ok := w.Annotations[models.AnnotationPrevious]
+	if !ok {
+		return nil, false, nil
+	}
+	if err := json.Unmarshal([]byte(value), &previous); err != nil {
+		return nil, true, fmt.Errorf("invalid %s annotation: %v", models.AnnotationPrevious, err)
+	}
+	return previous, true, nil
+}
+
+// Rollback restores the resources recorded by Apply and removes the apply
+// annotations. Only the last apply can be undone, since every apply records
+// the resources it replaced.
+func Rollback(clientset *kubernetes.Clientset, cluster string, w models.WorkLoad, dryRun bool) Result {
+	result := Result{Cluster: cluster, Namespace: w.Namespace, Kind: w.Kind, Name: w.Name}
+	previous, ok, err := Previous(w)
+	if err != nil {
+		result.Status, result.Message = "failed", err.Error()
+		return result
+	}
+	if !ok {
+		result.Status, result.Message = "skipped", "no previous resources recorded"
+		return result
+	}
+
+	var recs []models.Recommendation
+	for _, c := range w.Containers {
+		if rc, ok := previous[c.Name]; ok {
+			recs = append(recs, models.Recommendation{
+				ContainerName:      c.Name,
+				RecommendedRequest: models.ResourceConfig{Request: rc.Request},
+				RecommendedLimit:   models.ResourceConfig{Limits: rc.Limits},
+			})
+		}
+	}
+	strategic, err := patch.StrategicMerge(w, recs)
+	if err == nil {
+		strategic, err = patch.Annotate(strategic, map[string]string{
+			models.AnnotationPrevious:  "",
+			models.AnnotationAppliedAt: "",
+			models.AnnotationApplyRun:  "",
+		})
+	}
+	if err == nil {
+		err = k8s.PatchResources(clientset, w, strategic, dryRun)
+	}
+	switch {
+	case err != nil:
+		result.Status, result.Message = "failed", err.Error()
+	case dryRun:
+		result.Status = "dry-run"
+	default:
+		result.Status = "rolled-back"
+	}
+	if run := w.Annotations[models.AnnotationApplyRun]; run != "" && err == nil {
+		result.Message = fmt.Sprintf("run %s applied at %s", run, w.Annotations[models.AnnotationAppliedAt])
+	}
+	return result
+}
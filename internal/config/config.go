@@ -0,0 +1,510 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/tabed23/k8s-resource-tuner/internal/cost"
+	"github.com/tabed23/k8s-resource-tuner/internal/k8s"
+	"github.com/tabed23/k8s-resource-tuner/internal/recommendation"
+	"github.com/tabed23/k8s-resource-tuner/internal/vpa"
+	"gopkg.in/yaml.v3"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Environment variables that override secrets and endpoints from the file,
+// so credentials do not have to be committed alongside the configuration.
+const (
+	EnvPrometheusURL      = "TUNER_PROMETHEUS_URL"
+	EnvPrometheusToken    = "TUNER_PROMETHEUS_TOKEN"
+	EnvPrometheusUsername = "TUNER_PROMETHEUS_USERNAME"
+	EnvPrometheusPassword = "TUNER_PROMETHEUS_PASSWORD"
+	EnvSlackToken         = "TUNER_SLACK_TOKEN"
+	EnvSlackChannel       = "TUNER_SLACK_CHANNEL"
+)
+
+// Config holds every tunable of a run.
+type Config struct {
+	Namespaces NamespaceConfig `yaml:"namespaces"`
+	Selector   string          `yaml:"selector"`
+	// AnnotationSelector selects workloads by annotations, with the label
+	// selector syntax.
+	AnnotationSelector string           `yaml:"annotationSelector"`
+	Kube               KubeConfig       `yaml:"kube"`
+	Prometheus         PrometheusConfig `yaml:"prometheus"`
+	Clusters           []ClusterConfig  `yaml:"clusters"`
+	Lookback           time.Duration    `yaml:"lookback"`
+	Step               time.Duration    `yaml:"step"`
+	Policy             PolicyConfig     `yaml:"policy"`
+	Policies           PolicySpecs      `yaml:"policies"`
+	Outputs            OutputConfig     `yaml:"outputs"`
+	Apply              ApplyConfig      `yaml:"apply"`
+	GitOps             GitOpsConfig     `yaml:"gitops"`
+	Cost               CostConfig       `yaml:"cost"`
+	History            HistoryConfig    `yaml:"history"`
+	Notifiers          NotifierConfig   `yaml:"notifiers"`
+}
+
+type NamespaceConfig struct {
+	Names         []string `yaml:"names"`
+	All           bool     `yaml:"all"`
+	Include       []string `yaml:"include"`
+	Exclude       []string `yaml:"exclude"`
+	Selector      string   `yaml:"selector"`
+	IncludeSystem bool     `yaml:"includeSystem"`
+}
+
+type KubeConfig struct {
+	Kubeconfig        string   `yaml:"kubeconfig"`
+	Context           string   `yaml:"context"`
+	ImpersonateUser   string   `yaml:"impersonateUser"`
+	ImpersonateGroups []string `yaml:"impersonateGroups"`
+	QPS               float32  `yaml:"qps"`
+	Burst             int      `yaml:"burst"`
+}
+
+type PrometheusConfig struct {
+	URL         string        `yaml:"url"`
+	BearerToken string        `yaml:"bearerToken"`
+	Username    string        `yaml:"username"`
+	Password    string        `yaml:"password"`
+	Timeout     time.Duration `yaml:"timeout"`
+}
+
+// ClusterConfig is one scan target of a multi-cluster run. Unset Prometheus
+// credentials and timeout are inherited from the top-level prometheus section.
+type ClusterConfig struct {
+	Name       string           `yaml:"name"`
+	Context    string           `yaml:"context"`
+	Kubeconfig string           `yaml:"kubeconfig"`
+	Prometheus PrometheusConfig `yaml:"prometheus"`
+}
+
+type PolicyConfig struct {
+	// Default names the policy used when nothing more specific applies.
+	Default string `yaml:"default"`
+	// Namespaces maps a namespace to the policy its workloads use.
+	Namespaces map[string]string `yaml:"namespaces"`
+	// Workloads maps "namespace/name" to a policy, taking precedence over
+	// Namespaces. A resource-tuner/policy annotation overrides both.
+	Workloads map[string]string `yaml:"workloads"`
+}
+
+// PolicySpecs defines custom policies by name, next to the built-in
+// conservative, balanced and aggressive presets.
+type PolicySpecs map[string]PolicySpec
+
+// PolicySpec starts from the Base preset (balanced when empty) and
+// overrides the fields that are set.
+type PolicySpec struct {
+	Base   string             `yaml:"base"`
+	CPU    ResourcePolicySpec `yaml:"cpu"`
+	Memory ResourcePolicySpec `yaml:"memory"`
+	// CPULimit and MemoryLimit are percentile, none or request.
+	CPULimit    string `yaml:"cpuLimit"`
+	MemoryLimit string `yaml:"memoryLimit"`
+	// QoS is Guaranteed or Burstable.
+	QoS string `yaml:"qos"`
+}
+
+type ResourcePolicySpec struct {
+	RequestPercentile *float64 `yaml:"requestPercentile"`
+	LimitPercentile   *float64 `yaml:"limitPercentile"`
+	MarginPercent     *float64 `yaml:"marginPercent"`
+	Min               string   `yaml:"min"`
+	Max               string   `yaml:"max"`
+	Round             string   `yaml:"round"`
+}
+
+type OutputConfig struct {
+	Formats []string `yaml:"formats"`
+	// PatchDir, when set, receives per-workload patches and kubectl
+	// commands from the recommend command.
+	PatchDir string `yaml:"patchDir"`
+	// VPADir, when set, receives a VerticalPodAutoscaler per workload from
+	// the recommend command, in VPAUpdateMode (Off or Initial).
+	VPADir        string `yaml:"vpaDir"`
+	VPAUpdateMode string `yaml:"vpaUpdateMode"`
+	// CompareVPA reads the target of existing VerticalPodAutoscalers into
+	// the recommendations.
+	CompareVPA bool `yaml:"compareVPA"`
+}
+
+// ApplyConfig holds the safety gates of the apply command.
+type ApplyConfig struct {
+	// AllowedNamespaces are glob or re:<regex> patterns of the namespaces
+	// apply may patch. Nothing is patched while the list is empty.
+	AllowedNamespaces []string `yaml:"allowedNamespaces"`
+	// MaxChangePercent caps how far a value moves in one run, 0 disables it.
+	MaxChangePercent float64 `yaml:"maxChangePercent"`
+	// ProtectMemory never lowers memory below the observed maximum usage.
+	ProtectMemory bool `yaml:"protectMemory"`
+}
+
+// GitOpsConfig locates the manifests of the workloads in a local git
+// repository for the gitops command.
+type GitOpsConfig struct {
+	Repo string `yaml:"repo"`
+	// BranchPrefix is followed by the run ID in the name of the branch the
+	// changes are committed to.
+	BranchPrefix string             `yaml:"branchPrefix"`
+	Helm         []HelmValuesConfig `yaml:"helm"`
+}
+
+// HelmValuesConfig points at the values file setting the resources of a
+// workload deployed by a Helm chart.
+type HelmValuesConfig struct {
+	// Workload is "namespace/name".
+	Workload string `yaml:"workload"`
+	// Values is the path of the values file inside the repository.
+	Values string `yaml:"values"`
+	// Containers maps a container name to the dotted key path of its
+	// resources block, e.g. "api.resources".
+	Containers map[string]string `yaml:"containers"`
+}
+
+// CostConfig prices requests to estimate the monthly cost of the workloads
+// and the savings of the recommendations. Pricing is off while no price is
+// set.
+type CostConfig struct {
+	Currency string `yaml:"currency"`
+	// CPUHour and MemoryGiBHour are the prices of one vCPU and one GiB of
+	// memory for an hour.
+	CPUHour       float64 `yaml:"cpuHour"`
+	MemoryGiBHour float64 `yaml:"memoryGiBHour"`
+	// TeamLabel is the workload label the costs are grouped by.
+	TeamLabel string `yaml:"teamLabel"`
+	// NodePools price the nodes matching their label selector differently,
+	// the first matching pool wins.
+	NodePools []NodePoolConfig `yaml:"nodePools"`
+}
+
+type NodePoolConfig struct {
+	Name          string  `yaml:"name"`
+	Selector      string  `yaml:"selector"`
+	CPUHour       float64 `yaml:"cpuHour"`
+	MemoryGiBHour float64 `yaml:"memoryGiBHour"`
+}
+
+// HistoryConfig records every run in a local database when Path is set,
+// for the history command. Runs older than Retention are deleted when a
+// run is recorded; zero keeps every run.
+type HistoryConfig struct {
+	Path      string        `yaml:"path"`
+	Retention time.Duration `yaml:"retention"`
+}
+
+type NotifierConfig struct {
+	Slack SlackConfig `yaml:"slack"`
+}
+
+// SlackConfig enables the Slack upload when a token is set.
+type SlackConfig struct {
+	Token   string `yaml:"token"`
+	Channel string `yaml:"channel"`
+}
+
+// Default returns the configuration used when no file is given.
+func Default() Config {
+	return Config{
+		Namespaces: NamespaceConfig{Names: []string{"test"}},
+		Prometheus: PrometheusConfig{
+			URL:     "http://localhost:9090",
+			Timeout: 30 * time.Second,
+		},
+		Lookback: 9 * time.Hour,
+		Step:     60 * time.Second,
+		Policy:   PolicyConfig{Default: recommendation.DefaultPolicy.Name},
+		Outputs:  OutputConfig{Formats: []string{"pdf"}, VPAUpdateMode: string(vpa.UpdateModeOff)},
+		Apply:    ApplyConfig{MaxChangePercent: 50, ProtectMemory: true},
+		GitOps:   GitOpsConfig{BranchPrefix: "resource-tuner/"},
+		Cost:     CostConfig{Currency: "USD", TeamLabel: "team"},
+	}
+}
+
+// Load reads the YAML file at path on top of the defaults and applies the
+// environment overrides. An empty path skips the file. Callers run Validate
+// once command-line overrides are applied as well.
+func Load(path string) (Config, error) {
+	cfg := Default()
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return Config{}, fmt.Errorf("failed to read config: %v", err)
+		}
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+			return Config{}, fmt.Errorf("failed to parse config %s: %v", path, err)
+		}
+	}
+	cfg.ApplyEnv()
+	return cfg, nil
+}
+
+// ApplyEnv overrides endpoints and secrets from the TUNER_* variables.
+func (c *Config) ApplyEnv() {
+	override := func(dst *string, env string) {
+		if v, ok := os.LookupEnv(env); ok {
+			*dst = v
+		}
+	}
+	override(&c.Prometheus.URL, EnvPrometheusURL)
+	override(&c.Prometheus.BearerToken, EnvPrometheusToken)
+	override(&c.Prometheus.Username, EnvPrometheusUsername)
+	override(&c.Prometheus.Password, EnvPrometheusPassword)
+	override(&c.Notifiers.Slack.Token, EnvSlackToken)
+	override(&c.Notifiers.Slack.Channel, EnvSlackChannel)
+}
+
+// Validate reports every invalid value at once.
+func (c Config) Validate() error {
+	var errs []error
+	invalid := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("config: "+format, args...))
+	}
+
+	if !c.Namespaces.All && len(c.Namespaces.Names) == 0 {
+		invalid("namespaces.names must not be empty unless namespaces.all is set")
+	}
+	if c.Lookback <= 0 {
+		invalid("lookback must be positive, got %s", c.Lookback)
+	}
+	if c.Step <= 0 || c.Step%time.Second != 0 {
+		invalid("step must be a positive whole number of seconds, got %s", c.Step)
+	} else if c.Step >= c.Lookback {
+		invalid("step (%s) must be shorter than lookback (%s)", c.Step, c.Lookback)
+	}
+	if _, err := labels.Parse(c.Selector); err != nil {
+		invalid("selector: %v", err)
+	}
+	if _, err := labels.Parse(c.AnnotationSelector); err != nil {
+		invalid("annotationSelector: %v", err)
+	}
+	if c.Kube.QPS < 0 || c.Kube.Burst < 0 {
+		invalid("kube.qps and kube.burst must not be negative")
+	}
+
+	if err := validatePrometheus("prometheus", c.Prometheus); err != nil {
+		errs = append(errs, err)
+	}
+	names := map[string]bool{}
+	for i, cl := range c.Clusters {
+		if cl.Name == "" {
+			invalid("clusters[%d].name is required", i)
+		} else if names[cl.Name] {
+			invalid("clusters[%d].name %q is duplicated", i, cl.Name)
+		}
+		names[cl.Name] = true
+		if err := validatePrometheus(fmt.Sprintf("clusters[%d].prometheus", i), c.ClusterPrometheus(cl)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if set, err := c.PolicySet(); err != nil {
+		invalid("policies: %v", err)
+	} else if err := set.Validate(); err != nil {
+		invalid("policy: %v", err)
+	}
+
+	if len(c.Outputs.Formats) == 0 {
+		invalid("outputs.formats must not be empty")
+	}
+
+	if _, err := vpa.ParseUpdateMode(c.Outputs.VPAUpdateMode); err != nil {
+		invalid("outputs.vpaUpdateMode: %v", err)
+	}
+
+	if c.Apply.MaxChangePercent < 0 {
+		invalid("apply.maxChangePercent must not be negative, got %g", c.Apply.MaxChangePercent)
+	}
+	for _, pattern := range c.Apply.AllowedNamespaces {
+		if _, err := (k8s.NamespaceFilter{Include: []string{pattern}}).Matches(""); err != nil {
+			invalid("apply.allowedNamespaces: %v", err)
+		}
+	}
+
+	for i, h := range c.GitOps.Helm {
+		if ns, name, ok := strings.Cut(h.Workload, "/"); !ok || ns == "" || name == "" {
+			invalid("gitops.helm[%d].workload must be namespace/name, got %q", i, h.Workload)
+		}
+		if h.Values == "" {
+			invalid("gitops.helm[%d].values is required", i)
+		}
+		if len(h.Containers) == 0 {
+			invalid("gitops.helm[%d].containers must map at least one container to a key path", i)
+		}
+		for container, keyPath := range h.Containers {
+			if keyPath == "" || strings.HasPrefix(keyPath, ".") || strings.HasSuffix(keyPath, ".") || strings.Contains(keyPath, "..") {
+				invalid("gitops.helm[%d].containers.%s: invalid key path %q", i, container, keyPath)
+			}
+		}
+	}
+
+	if c.History.Retention < 0 {
+		invalid("history.retention must not be negative, got %s", c.History.Retention)
+	}
+
+	if c.Cost.CPUHour < 0 || c.Cost.MemoryGiBHour < 0 {
+		invalid("cost.cpuHour and cost.memoryGiBHour must not be negative")
+	}
+	for i, p := range c.Cost.NodePools {
+		if p.Name == "" {
+			invalid("cost.nodePools[%d].name is required", i)
+		}
+		if p.CPUHour < 0 || p.MemoryGiBHour < 0 {
+			invalid("cost.nodePools[%d] prices must not be negative", i)
+		}
+		if _, err := labels.Parse(p.Selector); err != nil || p.Selector == "" {
+			invalid("cost.nodePools[%d].selector must be a non-empty label selector, got %q", i, p.Selector)
+		}
+	}
+
+	if c.Notifiers.Slack.Token != "" && c.Notifiers.Slack.Channel == "" {
+		invalid("notifiers.slack.channel is required when a Slack token is set")
+	}
+	return errors.Join(errs...)
+}
+
+// WorkloadFilter returns the label and annotation selectors of the
+// workloads to analyze.
+func (c Config) WorkloadFilter() k8s.WorkloadFilter {
+	return k8s.WorkloadFilter{LabelSelector: c.Selector, AnnotationSelector: c.AnnotationSelector}
+}
+
+// ClusterPrometheus returns the Prometheus settings of a cluster with the
+// unset fields inherited from the top-level section.
+func (c Config) ClusterPrometheus(cl ClusterConfig) PrometheusConfig {
+	p := cl.Prometheus
+	if p.BearerToken == "" && p.Username == "" {
+		p.BearerToken = c.Prometheus.BearerToken
+		p.Username = c.Prometheus.Username
+		p.Password = c.Prometheus.Password
+	}
+	if p.Timeout == 0 {
+		p.Timeout = c.Prometheus.Timeout
+	}
+	return p
+}
+
+func validatePrometheus(field string, p PrometheusConfig) error {
+	u, err := url.Parse(p.URL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("config: %s.url must be an absolute URL, got %q", field, p.URL)
+	}
+	if p.BearerToken != "" && p.Username != "" {
+		return fmt.Errorf("config: %s sets both bearerToken and username, pick one", field)
+	}
+	if p.Timeout <= 0 {
+		return fmt.Errorf("config: %s.timeout must be positive", field)
+	}
+	return nil
+}
+
+// CostModel builds the pricing of the cost section. It returns false when
+// no price is set.
+func (c Config) CostModel() (cost.Model, bool, error) {
+	if c.Cost.CPUHour == 0 && c.Cost.MemoryGiBHour == 0 && len(c.Cost.NodePools) == 0 {
+		return cost.Model{}, false, nil
+	}
+	m := cost.Model{
+		Currency:  c.Cost.Currency,
+		Default:   cost.Price{CPUHour: c.Cost.CPUHour, MemoryGiBHour: c.Cost.MemoryGiBHour},
+		TeamLabel: c.Cost.TeamLabel,
+	}
+	for _, p := range c.Cost.NodePools {
+		selector, err := labels.Parse(p.Selector)
+		if err != nil {
+			return cost.Model{}, false, fmt.Errorf("cost.nodePools %s: %v", p.Name, err)
+		}
+		m.Pools = append(m.Pools, cost.NodePool{
+			Name:     p.Name,
+			Selector: selector,
+			Price:    cost.Price{CPUHour: p.CPUHour, MemoryGiBHour: p.MemoryGiBHour},
+		})
+	}
+	return m, true, nil
+}
+
+// PolicySet builds the policy selection from the presets, the custom
+// policies and the policy section.
+func (c Config) PolicySet() (recommendation.PolicySet, error) {
+	set := recommendation.PolicySet{
+		Policies:   recommendation.Presets(),
+		Default:    c.Policy.Default,
+		Namespaces: c.Policy.Namespaces,
+		Workloads:  c.Policy.Workloads,
+	}
+	for name, spec := range c.Policies {
+		p, err := spec.build(name)
+		if err != nil {
+			return recommendation.PolicySet{}, err
+		}
+		set.Policies[name] = p
+	}
+	return set, nil
+}
+
+func (spec PolicySpec) build(name string) (recommendation.Policy, error) {
+	base := recommendation.DefaultPolicy
+	if spec.Base != "" {
+		var err error
+		if base, err = recommendation.PolicyByName(spec.Base); err != nil {
+			return recommendation.Policy{}, fmt.Errorf("%s.base: %v", name, err)
+		}
+	}
+	p := base
+	p.Name = name
+	var err error
+	if p.CPU, err = spec.CPU.apply(base.CPU); err != nil {
+		return recommendation.Policy{}, fmt.Errorf("%s.cpu: %v", name, err)
+	}
+	if p.Memory, err = spec.Memory.apply(base.Memory); err != nil {
+		return recommendation.Policy{}, fmt.Errorf("%s.memory: %v", name, err)
+	}
+	if spec.CPULimit != "" {
+		p.CPULimit = recommendation.LimitMode(spec.CPULimit)
+	}
+	if spec.MemoryLimit != "" {
+		p.MemoryLimit = recommendation.LimitMode(spec.MemoryLimit)
+	}
+	if spec.QoS != "" {
+		p.QoS = v1.PodQOSClass(spec.QoS)
+	}
+	return p, nil
+}
+
+func (spec ResourcePolicySpec) apply(rp recommendation.ResourcePolicy) (recommendation.ResourcePolicy, error) {
+	if spec.RequestPercentile != nil {
+		rp.RequestPercentile = *spec.RequestPercentile
+	}
+	if spec.LimitPercentile != nil {
+		rp.LimitPercentile = *spec.LimitPercentile
+	}
+	if spec.MarginPercent != nil {
+		rp.MarginPercent = *spec.MarginPercent
+	}
+	for _, q := range []struct {
+		field string
+		value string
+		dst   *resource.Quantity
+	}{{"min", spec.Min, &rp.Min}, {"max", spec.Max, &rp.Max}, {"round", spec.Round, &rp.Round}} {
+		if q.value == "" {
+			continue
+		}
+		parsed, err := resource.ParseQuantity(q.value)
+		if err != nil {
+			return rp, fmt.Errorf("invalid %s %q: %v", q.field, q.value, err)
+		}
+		*q.dst = parsed
+	}
+	return rp, nil
+}
@@ -0,0 +1,131 @@
+// Package cost prices the CPU and memory requests of workloads, so that a
+// recommendation can be weighed in money.
+package cost
+
+import (
+	"math"
+
+	"github.com/tabed23/k8s-resource-tuner/internal/models"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// HoursPerMonth is the average length of a month.
+const HoursPerMonth = 730
+
+const gibibyte = 1024 * 1024 * 1024
+
+// Price is the hourly price of one vCPU and of one GiB of memory.
+type Price struct {
+	CPUHour       float64
+	MemoryGiBHour float64
+}
+
+// NodePool prices the nodes whose labels match Selector, e.g. a spot pool
+// or an instance type.
+type NodePool struct {
+	Name     string
+	Selector labels.Selector
+	Price    Price
+}
+
+// Model prices requests at the price of the node pool a pod runs on, or at
+// Default on the nodes that match no pool.
+type Model struct {
+	Currency string
+	Default  Price
+	Pools    []NodePool
+	// TeamLabel is the workload label naming the team that owns it.
+	TeamLabel string
+}
+
+// PriceOf returns the price of the first pool matching the node labels, or
+// the default price.
+func (m Model) PriceOf(nodeLabels map[string]string) Price {
+	for _, p := range m.Pools {
+		if p.Selector.Matches(labels.Set(nodeLabels)) {
+			return p.Price
+		}
+	}
+	return m.Default
+}
+
+// Estimate sets the monthly cost of the current and recommended requests of
+// every container of the entries, and their sum on the entries. Requests
+// count for the replicas of ReportEntry.ReplicaCount, so Jobs and CronJobs
+// are priced for the time they ran over the lookback window. Replicas are
+// priced at the average price of the nodes the pending and running pods are
+// on, nodes mapping node names to their labels, or at the default price
+// without pods. Init containers are left out since they do not run next to
+// the others.
+func (m Model) Estimate(entries []models.ReportEntry, nodes map[string]map[string]string) {
+	for i := range entries {
+		e := &entries[i]
+		w := e.Workload
+		price, n := m.podPrice(w, nodes), e.ReplicaCount()
+
+		total := &models.Cost{Currency: m.Currency}
+		for j := range e.Recommendation {
+			rec := &e.Recommendation[j]
+			var current models.ResourceConfig
+			role := models.RoleApp
+			for _, c := range w.Containers {
+				if c.Name == rec.ContainerName {
+					current, role = c.Resources, c.Role
+				}
+			}
+			if role == models.RoleInit {
+				continue
+			}
+			c := &models.Cost{Currency: m.Currency}
+			c.Current = round(monthly(current.Request, price) * n)
+			c.Recommended = round(monthly(rec.RecommendedRequest.Request, price) * n)
+			c.Savings = round(c.Current - c.Recommended)
+			rec.Cost = c
+			total.Current += c.Current
+			total.Recommended += c.Recommended
+		}
+		total.Current, total.Recommended = round(total.Current), round(total.Recommended)
+		total.Savings = round(total.Current - total.Recommended)
+		e.Cost = total
+		if m.TeamLabel != "" {
+			e.Team = w.Labels[m.TeamLabel]
+		}
+	}
+}
+
+// podPrice averages the price of the nodes the pods of the workload are on.
+// Pods not scheduled yet and workloads without pods get the default price.
+func (m Model) podPrice(w models.WorkLoad, nodes map[string]map[string]string) Price {
+	if len(w.Pods) == 0 {
+		return m.Default
+	}
+	var sum Price
+	for j := range w.Pods {
+		p := m.Default
+		if j < len(w.Nodes) && w.Nodes[j] != "" {
+			p = m.PriceOf(nodes[w.Nodes[j]])
+		}
+		sum.CPUHour += p.CPUHour
+		sum.MemoryGiBHour += p.MemoryGiBHour
+	}
+	n := float64(len(w.Pods))
+	return Price{CPUHour: sum.CPUHour / n, MemoryGiBHour: sum.MemoryGiBHour / n}
+}
+
+// monthly prices the CPU and memory of one replica for a month.
+func monthly(requests v1.ResourceList, p Price) float64 {
+	var hourly float64
+	if q, ok := requests[v1.ResourceCPU]; ok {
+		hourly += q.AsApproximateFloat64() * p.CPUHour
+	}
+	if q, ok := requests[v1.ResourceMemory]; ok {
+		hourly += q.AsApproximateFloat64() / gibibyte * p.MemoryGiBHour
+	}
+	return hourly * HoursPerMonth
+}
+
+// round keeps cents.
+func round(v float64) float64 {
+	return math.Round(v*100) / 100
+}
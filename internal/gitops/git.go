@@ -0,0 +1,41 @@
+package gitops
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// git runs a git command in the repository and returns its output.
+func git(dir string, args ...string) (string, error) {
+	out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %v: %s", strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return string(out), nil
+}
+
+// EnsureClean fails when the repository has uncommitted changes, which the
+// commit would otherwise mix with the tuner's.
+func EnsureClean(dir string) error {
+	out, err := git(dir, "status", "--porcelain")
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(out) != "" {
+		return fmt.Errorf("manifest repository %s has uncommitted changes", dir)
+	}
+	return nil
+}
+
+// Commit creates branch from the current HEAD and commits the files to it.
+func Commit(dir, branch, message string, files []string) error {
+	if _, err := git(dir, "checkout", "-b", branch); err != nil {
+		return err
+	}
+	if _, err := git(dir, append([]string{"add", "--"}, files...)...); err != nil {
+		return err
+	}
+	_, err := git(dir, "commit", "-m", message)
+	return err
+}
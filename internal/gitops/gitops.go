@@ -0,0 +1,276 @@
+package gitops
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/tabed23/k8s-resource-tuner/internal/models"
+	"github.com/tabed23/k8s-resource-tuner/internal/patch"
+	"gopkg.in/yaml.v3"
+	v1 "k8s.io/api/core/v1"
+)
+
+// managed are the resources the tuner recommends. Other entries of the
+// resources blocks are kept.
+var managed = []v1.ResourceName{v1.ResourceCPU, v1.ResourceMemory}
+
+// HelmValues maps a workload deployed by a Helm chart to the values file
+// that sets its resources.
+type HelmValues struct {
+	// Workload is "namespace/name".
+	Workload string
+	// File is the values file, relative to the repository.
+	File string
+	// Containers maps a container name to the dotted key path of its
+	// resources block in the values file, e.g. "api.resources".
+	Containers map[string]string
+}
+
+// Edit is one workload rewritten in one file.
+type Edit struct {
+	Cluster   string `json:"cluster,omitempty"`
+	Namespace string `json:"namespace"`
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	File      string `json:"file"`
+	Source    string `json:"source"` // manifest or helm
+}
+
+// Repo is a local checkout of the manifests the workloads are deployed from.
+type Repo struct {
+	Dir  string
+	Helm []HelmValues
+
+	files map[string]*yamlFile
+}
+
+type yamlFile struct {
+	docs   []*yaml.Node
+	editor *editor
+}
+
+// Open parses every YAML file of the repository. Files that are not valid
+// YAML, such as Helm templates, are ignored.
+func Open(dir string, helm []HelmValues) (*Repo, error) {
+	r := &Repo{Dir: dir, Helm: helm, files: map[string]*yamlFile{}}
+	err := filepath.WalkDir(dir, func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if file != dir && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if ext := filepath.Ext(file); ext != ".yaml" && ext != ".yml" && d.Name() != "Kustomization" {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+		if f, err := parseFile(file); err == nil {
+			r.files[rel] = f
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest repository %s: %v", dir, err)
+	}
+	return r, nil
+}
+
+func parseFile(file string) (*yamlFile, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	f := &yamlFile{editor: newEditor(data)}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var doc yaml.Node
+		if err := dec.Decode(&doc); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if len(doc.Content) > 0 {
+			f.docs = append(f.docs, doc.Content[0])
+		}
+	}
+	return f, nil
+}
+
+// Rewrite sets the resources of the entry's workload to the recommended
+// values wherever the repository defines them: in the Helm values file
+// configured for the workload, or else in every manifest and Kustomize
+// strategic merge patch of the same kind, name and namespace.
+func (r *Repo) Rewrite(e models.ReportEntry) ([]Edit, error) {
+	w := e.Workload
+	newEdit := func(file, source string) Edit {
+		return Edit{Cluster: e.Cluster, Namespace: w.Namespace, Kind: w.Kind, Name: w.Name, File: file, Source: source}
+	}
+
+	for _, h := range r.Helm {
+		if h.Workload != w.Namespace+"/"+w.Name {
+			continue
+		}
+		f, ok := r.files[h.File]
+		if !ok {
+			return nil, fmt.Errorf("helm values %s of %s not found or not valid YAML", h.File, h.Workload)
+		}
+		if len(f.docs) == 0 {
+			return nil, fmt.Errorf("helm values %s is empty", h.File)
+		}
+		for _, rec := range e.Recommendation {
+			keyPath, ok := h.Containers[rec.ContainerName]
+			if !ok {
+				continue
+			}
+			keys := strings.Split(keyPath, ".")
+			parent := path(f.docs[0], keys[:len(keys)-1]...)
+			if parent == nil || parent.Kind != yaml.MappingNode {
+				return nil, fmt.Errorf("%s: key path %q not found", h.File, keyPath)
+			}
+			requests, limits := desired(rec)
+			if err := f.editor.setResources(parent, keys[len(keys)-1], requests, limits); err != nil {
+				return nil, fmt.Errorf("%s: %v", h.File, err)
+			}
+		}
+		return []Edit{newEdit(h.File, "helm")}, nil
+	}
+
+	templatePath, err := patch.TemplatePath(w.Kind)
+	if err != nil {
+		return nil, err
+	}
+	var edits []Edit
+	for _, name := range r.fileNames() {
+		f := r.files[name]
+		found := false
+		for _, doc := range f.docs {
+			if !r.defines(name, doc, w) {
+				continue
+			}
+			podSpec := path(doc, templatePath...)
+			for _, rec := range e.Recommendation {
+				container := findContainer(podSpec, rec.ContainerName)
+				if container == nil {
+					continue
+				}
+				requests, limits := desired(rec)
+				if err := f.editor.setResources(container, "resources", requests, limits); err != nil {
+					return edits, fmt.Errorf("%s: %v", name, err)
+				}
+				found = true
+			}
+		}
+		if found {
+			edits = append(edits, newEdit(name, "manifest"))
+		}
+	}
+	return edits, nil
+}
+
+// defines reports whether a document is the workload or a patch of it. A
+// document without a namespace matches unless the nearest kustomization
+// sets another one.
+func (r *Repo) defines(file string, doc *yaml.Node, w models.WorkLoad) bool {
+	kind, name := path(doc, "kind"), path(doc, "metadata", "name")
+	if kind == nil || name == nil || kind.Value != w.Kind || name.Value != w.Name {
+		return false
+	}
+	if ns := path(doc, "metadata", "namespace"); ns != nil {
+		return ns.Value == w.Namespace
+	}
+	ns := r.kustomizeNamespace(filepath.Dir(file))
+	return ns == "" || ns == w.Namespace
+}
+
+// kustomizeNamespace returns the namespace set by the kustomization of dir
+// or of the closest parent directory that has one.
+func (r *Repo) kustomizeNamespace(dir string) string {
+	for {
+		for _, name := range []string{"kustomization.yaml", "kustomization.yml", "Kustomization"} {
+			if f, ok := r.files[filepath.Join(dir, name)]; ok && len(f.docs) > 0 {
+				if ns := path(f.docs[0], "namespace"); ns != nil {
+					return ns.Value
+				}
+			}
+		}
+		if dir == "." || dir == "/" {
+			return ""
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+func findContainer(podSpec *yaml.Node, name string) *yaml.Node {
+	for _, field := range []string{"containers", "initContainers"} {
+		list := path(podSpec, field)
+		if list == nil || list.Kind != yaml.SequenceNode {
+			continue
+		}
+		for _, c := range list.Content {
+			if n := path(c, "name"); n != nil && n.Value == name {
+				return c
+			}
+		}
+	}
+	return nil
+}
+
+// desired returns the requests and limits blocks a recommendation asks
+// for. Managed resources the recommendation leaves out, such as a CPU limit
+// under a no-CPU-limit policy, are dropped.
+func desired(rec models.Recommendation) (resourceValues, resourceValues) {
+	values := func(recommended v1.ResourceList) resourceValues {
+		rv := resourceValues{set: recommended}
+		for _, name := range managed {
+			if _, ok := recommended[name]; !ok {
+				rv.drop = append(rv.drop, name)
+			}
+		}
+		return rv
+	}
+	return values(rec.RecommendedRequest.Request), values(rec.RecommendedLimit.Limits)
+}
+
+func (r *Repo) fileNames() []string {
+	names := make([]string, 0, len(r.files))
+	for name := range r.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Save writes the rewritten files and returns their paths relative to the
+// repository.
+func (r *Repo) Save() ([]string, error) {
+	var saved []string
+	for _, name := range r.fileNames() {
+		f := r.files[name]
+		if !f.editor.changed() {
+			continue
+		}
+		file := filepath.Join(r.Dir, name)
+		info, err := os.Stat(file)
+		if err != nil {
+			return saved, fmt.Errorf("failed to write %s: %v", name, err)
+		}
+		if err := os.WriteFile(file, f.editor.bytes(), info.Mode().Perm()); err != nil {
+			return saved, fmt.Errorf("failed to write %s: %v", name, err)
+		}
+		saved = append(saved, name)
+	}
+	return saved, nil
+}
@@ -0,0 +1,350 @@
+package gitops
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+	v1 "k8s.io/api/core/v1"
+)
+
+// indentStep is the indentation of the blocks the editor adds.
+const indentStep = 2
+
+// editor records line based edits of a YAML file, so that everything it
+// does not touch, comments and formatting included, is kept byte for byte.
+// Positions come from the yaml.v3 nodes parsed from the original text.
+type editor struct {
+	lines []string
+	edits map[int]*lineEdit // by 0-based line index
+}
+
+type lineEdit struct {
+	// replace swaps the bytes [from, to) of the line for text.
+	replace  bool
+	from, to int
+	text     string
+	delete   bool
+	after    []string
+}
+
+func newEditor(data []byte) *editor {
+	return &editor{lines: strings.Split(string(data), "\n"), edits: map[int]*lineEdit{}}
+}
+
+func (e *editor) edit(line int) *lineEdit {
+	le, ok := e.edits[line-1]
+	if !ok {
+		le = &lineEdit{}
+		e.edits[line-1] = le
+	}
+	return le
+}
+
+func (e *editor) changed() bool {
+	return len(e.edits) > 0
+}
+
+// bytes returns the edited text. Edits are applied from the last line up so
+// that line indexes stay valid.
+func (e *editor) bytes() []byte {
+	lines := append([]string(nil), e.lines...)
+	indexes := make([]int, 0, len(e.edits))
+	for i := range e.edits {
+		indexes = append(indexes, i)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(indexes)))
+	for _, i := range indexes {
+		le := e.edits[i]
+		if len(le.after) > 0 {
+			lines = append(lines[:i+1], append(append([]string(nil), le.after...), lines[i+1:]...)...)
+		}
+		if le.replace {
+			lines[i] = lines[i][:le.from] + le.text + lines[i][le.to:]
+		}
+		if le.delete {
+			lines = append(lines[:i], lines[i+1:]...)
+		}
+	}
+	return []byte(strings.Join(lines, "\n"))
+}
+
+// setScalar replaces the text of a single line scalar, keeping its quoting
+// style and any trailing comment.
+func (e *editor) setScalar(n *yaml.Node, value string) error {
+	if n.Kind != yaml.ScalarNode {
+		return fmt.Errorf("line %d: expected a scalar", n.Line)
+	}
+	line := e.lines[n.Line-1]
+	from := byteOffset(line, n.Column-1)
+	to := scalarEnd(line, from)
+	switch {
+	case n.Style&yaml.DoubleQuotedStyle != 0:
+		value = `"` + value + `"`
+	case n.Style&yaml.SingleQuotedStyle != 0:
+		value = "'" + value + "'"
+	}
+	if line[from:to] == value {
+		return nil
+	}
+	le := e.edit(n.Line)
+	le.replace, le.from, le.to, le.text = true, from, to, value
+	return nil
+}
+
+// replaceValue drops the inline value of a key, such as "{}" or "null", and
+// puts the given block lines below it instead.
+func (e *editor) replaceValue(key, value *yaml.Node, block []string) error {
+	if value.Kind != yaml.ScalarNode && (value.Line != key.Line || e.endLine(value, key.Column-1) != key.Line) {
+		return fmt.Errorf("line %d: multi-line flow values are not supported", key.Line)
+	}
+	line := e.lines[key.Line-1]
+	colon := strings.Index(line[byteOffset(line, key.Column-1):], ":")
+	if colon < 0 {
+		return fmt.Errorf("line %d: expected a mapping key", key.Line)
+	}
+	from := byteOffset(line, key.Column-1) + colon + 1
+	to := len(line)
+	if i := strings.Index(line[from:], " #"); i >= 0 {
+		to = from + i
+	}
+	le := e.edit(key.Line)
+	le.replace, le.from, le.to, le.text = true, from, to, ""
+	e.insertAfter(key.Line, block...)
+	return nil
+}
+
+// insertAfter adds lines after the given 1-based line.
+func (e *editor) insertAfter(line int, text ...string) {
+	if len(text) == 0 {
+		return
+	}
+	le := e.edit(line)
+	le.after = append(le.after, text...)
+}
+
+// deleteEntry removes a key and its value, which may span several lines.
+func (e *editor) deleteEntry(key, value *yaml.Node) {
+	for line := key.Line; line <= e.endLine(value, key.Column-1); line++ {
+		e.edit(line).delete = true
+	}
+}
+
+// endLine returns the last line of a node nested under indent. Past the
+// last line known from the node positions it follows the lines indented
+// deeper than indent, which covers multi-line scalars.
+func (e *editor) endLine(n *yaml.Node, indent int) int {
+	last := lastLine(n)
+	for j := last + 1; j <= len(e.lines); j++ {
+		line := e.lines[j-1]
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if len(line)-len(strings.TrimLeft(line, " ")) <= indent {
+			break
+		}
+		last = j
+	}
+	return last
+}
+
+// scalarEnd returns the end of the scalar starting at from: the closing
+// quote of a quoted scalar, or the start of a trailing comment.
+func scalarEnd(line string, from int) int {
+	if from < len(line) && (line[from] == '"' || line[from] == '\'') {
+		if i := strings.IndexByte(line[from+1:], line[from]); i >= 0 {
+			return from + i + 2
+		}
+	}
+	end := len(line)
+	if i := strings.Index(line[from:], " #"); i >= 0 {
+		end = from + i
+	}
+	return from + len(strings.TrimRight(line[from:end], " \t"))
+}
+
+// byteOffset converts a 0-based column in characters to a byte offset.
+func byteOffset(line string, column int) int {
+	offset := 0
+	for i := 0; i < column && offset < len(line); i++ {
+		_, size := utf8.DecodeRuneInString(line[offset:])
+		offset += size
+	}
+	return offset
+}
+
+// lastLine returns the last line a node spans.
+func lastLine(n *yaml.Node) int {
+	last := n.Line
+	for _, c := range n.Content {
+		if l := lastLine(c); l > last {
+			last = l
+		}
+	}
+	return last
+}
+
+// lookup returns the key and value nodes of a mapping entry.
+func lookup(mapping *yaml.Node, key string) (*yaml.Node, *yaml.Node) {
+	if mapping == nil || mapping.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i], mapping.Content[i+1]
+		}
+	}
+	return nil, nil
+}
+
+// path follows mapping keys from node and returns the value at the end.
+func path(node *yaml.Node, keys ...string) *yaml.Node {
+	for _, k := range keys {
+		if _, node = lookup(node, k); node == nil {
+			return nil
+		}
+	}
+	return node
+}
+
+// keyIndent returns the indentation of the keys of a block mapping.
+func keyIndent(mapping *yaml.Node) int {
+	if len(mapping.Content) == 0 {
+		return mapping.Column - 1
+	}
+	return mapping.Content[0].Column - 1
+}
+
+// isBlockMapping reports whether entries can be edited in place.
+func isBlockMapping(n *yaml.Node) bool {
+	return n != nil && n.Kind == yaml.MappingNode && n.Style&yaml.FlowStyle == 0 && len(n.Content) > 0
+}
+
+// resourceValues is the desired content of a requests or limits block:
+// values to set and managed entries to drop.
+type resourceValues struct {
+	set  v1.ResourceList
+	drop []v1.ResourceName
+}
+
+// setResources makes the mapping entry key of parent, a container or a
+// Helm values section, hold the desired requests and limits. Entries of
+// the block that are not managed by the tuner are kept.
+func (e *editor) setResources(parent *yaml.Node, key string, requests, limits resourceValues) error {
+	k, v := lookup(parent, key)
+	if k == nil {
+		indent := keyIndent(parent)
+		if block := renderBlock(indent, key, requests, limits); len(block) > 0 {
+			e.insertAfter(e.endLine(parent, indent), block...)
+		}
+		return nil
+	}
+	if !isBlockMapping(v) {
+		block := renderBlock(k.Column-1, key, requests, limits)
+		if len(block) == 0 {
+			return nil
+		}
+		return e.replaceValue(k, v, block[1:])
+	}
+
+	indent := keyIndent(v)
+	for _, f := range []struct {
+		name string
+		want resourceValues
+	}{{"requests", requests}, {"limits", limits}} {
+		fk, fv := lookup(v, f.name)
+		switch {
+		case fk == nil && f.name == "requests":
+			// Added right below the key so that requests stay ahead of
+			// the limits, whose new entries go to the end of the block.
+			e.insertAfter(k.Line, renderField(indent, f.name, f.want.set)...)
+		case fk == nil:
+			e.insertAfter(e.endLine(v, k.Column-1), renderField(indent, f.name, f.want.set)...)
+		case !isBlockMapping(fv):
+			if block := renderField(fk.Column-1, f.name, f.want.set); len(block) > 0 {
+				if err := e.replaceValue(fk, fv, block[1:]); err != nil {
+					return err
+				}
+			}
+		default:
+			if err := e.setValues(fk, fv, f.want); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// setValues edits the entries of an existing requests or limits block.
+func (e *editor) setValues(key, mapping *yaml.Node, want resourceValues) error {
+	remaining := len(mapping.Content) / 2
+	var missing []string
+	for _, name := range sortedNames(want.set) {
+		q := want.set[name]
+		if _, vn := lookup(mapping, string(name)); vn != nil {
+			if err := e.setScalar(vn, q.String()); err != nil {
+				return err
+			}
+		} else {
+			missing = append(missing, fmt.Sprintf("%s%s: %s", strings.Repeat(" ", keyIndent(mapping)), name, q.String()))
+			remaining++
+		}
+	}
+	var drops [][2]*yaml.Node
+	for _, name := range want.drop {
+		if kn, vn := lookup(mapping, string(name)); kn != nil {
+			drops = append(drops, [2]*yaml.Node{kn, vn})
+			remaining--
+		}
+	}
+	if remaining == 0 {
+		// Nothing is left, drop the whole block rather than leaving an
+		// empty requests or limits key behind.
+		e.deleteEntry(key, mapping)
+		return nil
+	}
+	for _, d := range drops {
+		e.deleteEntry(d[0], d[1])
+	}
+	if len(missing) > 0 {
+		e.insertAfter(e.endLine(mapping, key.Column-1), missing...)
+	}
+	return nil
+}
+
+// renderBlock renders key with its requests and limits, or nothing when
+// there is no value to set.
+func renderBlock(indent int, key string, requests, limits resourceValues) []string {
+	lines := append(renderField(indent+indentStep, "requests", requests.set),
+		renderField(indent+indentStep, "limits", limits.set)...)
+	if len(lines) == 0 {
+		return nil
+	}
+	return append([]string{strings.Repeat(" ", indent) + key + ":"}, lines...)
+}
+
+// renderField renders a requests or limits block.
+func renderField(indent int, field string, values v1.ResourceList) []string {
+	if len(values) == 0 {
+		return nil
+	}
+	lines := []string{strings.Repeat(" ", indent) + field + ":"}
+	for _, name := range sortedNames(values) {
+		q := values[name]
+		lines = append(lines, fmt.Sprintf("%s%s: %s", strings.Repeat(" ", indent+indentStep), name, q.String()))
+	}
+	return lines
+}
+
+// sortedNames orders resource names alphabetically, which puts cpu before
+// memory.
+func sortedNames(list v1.ResourceList) []v1.ResourceName {
+	names := make([]v1.ResourceName, 0, len(list))
+	for name := range list {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+	return names
+}
@@ -0,0 +1,574 @@
+package gitops
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tabed23/k8s-resource-tuner/internal/models"
+	"gopkg.in/yaml.v3"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func resources(values ...string) v1.ResourceList {
+	l := v1.ResourceList{}
+	for i := 0; i+1 < len(values); i += 2 {
+		l[v1.ResourceName(values[i])] = resource.MustParse(values[i+1])
+	}
+	return l
+}
+
+// decode parses every document of a YAML file.
+func decode(t *testing.T, data string) []*yaml.Node {
+	t.Helper()
+	var docs []*yaml.Node
+	dec := yaml.NewDecoder(strings.NewReader(data))
+	for {
+		var doc yaml.Node
+		if err := dec.Decode(&doc); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			t.Fatalf("invalid YAML: %v\n%s", err, data)
+		}
+		if len(doc.Content) > 0 {
+			docs = append(docs, doc.Content[0])
+		}
+	}
+	return docs
+}
+
+// target returns the mapping holding the resources key: the app container
+// of the api Deployment, or the parent of the last key of a Helm key path.
+func target(doc *yaml.Node, keyPath string) (*yaml.Node, string) {
+	if keyPath != "" {
+		keys := strings.Split(keyPath, ".")
+		return path(doc, keys[:len(keys)-1]...), keys[len(keys)-1]
+	}
+	if kind, name := path(doc, "kind"), path(doc, "metadata", "name"); kind == nil || name == nil ||
+		kind.Value != models.KindDeployment || name.Value != "api" {
+		return nil, ""
+	}
+	return findContainer(path(doc, "spec", "template", "spec"), "app"), "resources"
+}
+
+type block struct {
+	Requests map[string]string `yaml:"requests"`
+	Limits   map[string]string `yaml:"limits"`
+}
+
+func TestSetResources(t *testing.T) {
+	tuned := models.Recommendation{
+		RecommendedRequest: models.ResourceConfig{Request: resources("cpu", "250m", "memory", "256Mi")},
+		RecommendedLimit:   models.ResourceConfig{Limits: resources("memory", "512Mi")},
+	}
+	noLimits := models.Recommendation{
+		RecommendedRequest: models.ResourceConfig{Request: resources("cpu", "100m", "memory", "128Mi")},
+	}
+	tunedBlock := block{
+		Requests: map[string]string{"cpu": "250m", "memory": "256Mi"},
+		Limits:   map[string]string{"memory": "512Mi"},
+	}
+	const deployment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: api
+spec:
+  template:
+    spec:
+      containers:
+        - name: app
+          image: api:1.0
+`
+
+	tests := []struct {
+		name    string
+		keyPath string
+		rec     models.Recommendation
+		in      string
+		want    string
+		block   block
+	}{
+		{
+			name: "quoted and commented scalars",
+			rec:  tuned,
+			in: deployment + `          resources:
+            requests:
+              cpu: "500m" # tuned by hand
+              memory: '1Gi'
+            limits:
+              cpu: 1
+              memory: 2Gi # hard cap
+`,
+			want: deployment + `          resources:
+            requests:
+              cpu: "250m" # tuned by hand
+              memory: '256Mi'
+            limits:
+              memory: 512Mi # hard cap
+`,
+			block: tunedBlock,
+		},
+		{
+			name: "empty resources",
+			rec:  tuned,
+			in: deployment + `          resources: {}
+          ports:
+            - containerPort: 8080
+`,
+			want: deployment + `          resources:
+            requests:
+              cpu: 250m
+              memory: 256Mi
+            limits:
+              memory: 512Mi
+          ports:
+            - containerPort: 8080
+`,
+			block: tunedBlock,
+		},
+		{
+			name: "empty resources with a comment",
+			rec:  tuned,
+			in: deployment + `          resources: {} # set by the tuner
+`,
+			want: deployment + `          resources: # set by the tuner
+            requests:
+              cpu: 250m
+              memory: 256Mi
+            limits:
+              memory: 512Mi
+`,
+			block: tunedBlock,
+		},
+		{
+			name: "missing resources",
+			rec:  tuned,
+			in:   deployment + `          args: ["--verbose"]`,
+			want: deployment + `          args: ["--verbose"]
+          resources:
+            requests:
+              cpu: 250m
+              memory: 256Mi
+            limits:
+              memory: 512Mi`,
+			block: tunedBlock,
+		},
+		{
+			name: "missing requests block",
+			rec:  tuned,
+			in: deployment + `          resources:
+            limits:
+              memory: 1Gi
+`,
+			want: deployment + `          resources:
+            requests:
+              cpu: 250m
+              memory: 256Mi
+            limits:
+              memory: 512Mi
+`,
+			block: tunedBlock,
+		},
+		{
+			name: "missing request value",
+			rec:  tuned,
+			in: deployment + `          resources:
+            requests:
+              memory: 1Gi
+            limits:
+              memory: 1Gi
+`,
+			want: deployment + `          resources:
+            requests:
+              memory: 256Mi
+              cpu: 250m
+            limits:
+              memory: 512Mi
+`,
+			block: tunedBlock,
+		},
+		{
+			name: "dropped limits",
+			rec:  noLimits,
+			in: deployment + `          resources:
+            requests:
+              cpu: 1
+              memory: 1Gi
+            limits:
+              cpu: 2
+              memory: 2Gi
+          ports:
+            - containerPort: 8080
+`,
+			want: deployment + `          resources:
+            requests:
+              cpu: 100m
+              memory: 128Mi
+          ports:
+            - containerPort: 8080
+`,
+			block: block{Requests: map[string]string{"cpu": "100m", "memory": "128Mi"}},
+		},
+		{
+			name: "dropped limits keep other resources",
+			rec:  noLimits,
+			in: deployment + `          resources:
+            requests:
+              cpu: 1
+              memory: 1Gi
+            limits:
+              cpu: 2
+              nvidia.com/gpu: 1
+              memory: 2Gi
+`,
+			want: deployment + `          resources:
+            requests:
+              cpu: 100m
+              memory: 128Mi
+            limits:
+              nvidia.com/gpu: 1
+`,
+			block: block{
+				Requests: map[string]string{"cpu": "100m", "memory": "128Mi"},
+				Limits:   map[string]string{"nvidia.com/gpu": "1"},
+			},
+		},
+		{
+			name: "multi-document file",
+			rec:  tuned,
+			in: `apiVersion: v1
+kind: Service
+metadata:
+  name: api
+spec:
+  ports:
+    - port: 80
+---
+` + deployment + `          resources:
+            requests:
+              cpu: 1
+              memory: 1Gi
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: worker
+spec:
+  template:
+    spec:
+      containers:
+        - name: app
+          resources:
+            requests:
+              cpu: 1
+`,
+			want: `apiVersion: v1
+kind: Service
+metadata:
+  name: api
+spec:
+  ports:
+    - port: 80
+---
+` + deployment + `          resources:
+            requests:
+              cpu: 250m
+              memory: 256Mi
+            limits:
+              memory: 512Mi
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: worker
+spec:
+  template:
+    spec:
+      containers:
+        - name: app
+          resources:
+            requests:
+              cpu: 1
+`,
+			block: tunedBlock,
+		},
+		{
+			name:    "helm key path",
+			keyPath: "api.resources",
+			rec:     tuned,
+			in: `image:
+  tag: "1.0"
+api:
+  replicas: 2
+  resources:
+    requests:
+      cpu: 1
+      memory: 1Gi
+worker:
+  resources: {}
+`,
+			want: `image:
+  tag: "1.0"
+api:
+  replicas: 2
+  resources:
+    requests:
+      cpu: 250m
+      memory: 256Mi
+    limits:
+      memory: 512Mi
+worker:
+  resources: {}
+`,
+			block: tunedBlock,
+		},
+		{
+			name:    "helm key path without resources",
+			keyPath: "worker.resources",
+			rec:     tuned,
+			in: `worker:
+  replicas: 1
+  command: |
+    run --all
+
+    run --again
+api:
+  replicas: 2
+`,
+			want: `worker:
+  replicas: 1
+  command: |
+    run --all
+
+    run --again
+  resources:
+    requests:
+      cpu: 250m
+      memory: 256Mi
+    limits:
+      memory: 512Mi
+api:
+  replicas: 2
+`,
+			block: tunedBlock,
+		},
+		{
+			name:    "UTF-8 columns",
+			keyPath: "café.resources",
+			rec:     tuned,
+			in: `café:
+  resources:
+    requests: {cpu: 1, memory: 1Gi} # à régler
+    limits:
+      memory: "1Gi" # ≈ deux fois la moyenne
+`,
+			want: `café:
+  resources:
+    requests: # à régler
+      cpu: 250m
+      memory: 256Mi
+    limits:
+      memory: "512Mi" # ≈ deux fois la moyenne
+`,
+			block: tunedBlock,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newEditor([]byte(tt.in))
+			found := false
+			for _, doc := range decode(t, tt.in) {
+				parent, key := target(doc, tt.keyPath)
+				if parent == nil {
+					continue
+				}
+				requests, limits := desired(tt.rec)
+				if err := e.setResources(parent, key, requests, limits); err != nil {
+					t.Fatal(err)
+				}
+				found = true
+			}
+			if !found {
+				t.Fatal("no resources to edit")
+			}
+
+			got := string(e.bytes())
+			if got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+			found = false
+			for _, doc := range decode(t, got) {
+				parent, key := target(doc, tt.keyPath)
+				if parent == nil {
+					continue
+				}
+				var b block
+				if _, v := lookup(parent, key); v == nil {
+					t.Fatalf("%s not found after the edit", key)
+				} else if err := v.Decode(&b); err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(b, tt.block) {
+					t.Errorf("decoded %+v, want %+v", b, tt.block)
+				}
+				found = true
+			}
+			if !found {
+				t.Error("edited resources not found")
+			}
+		})
+	}
+}
+
+func TestSetResourcesUnchanged(t *testing.T) {
+	in := `resources:
+  requests:
+    cpu: 250m # fine
+    memory: "256Mi"
+`
+	e := newEditor([]byte(in))
+	requests, limits := desired(models.Recommendation{
+		RecommendedRequest: models.ResourceConfig{Request: resources("cpu", "250m", "memory", "256Mi")},
+	})
+	if err := e.setResources(decode(t, in)[0], "resources", requests, limits); err != nil {
+		t.Fatal(err)
+	}
+	if e.changed() {
+		t.Errorf("unchanged values were edited:\n%s", e.bytes())
+	}
+}
+
+func TestReplaceValueMultiLineFlow(t *testing.T) {
+	in := `resources: {
+  requests: {cpu: 1}
+}
+`
+	doc := decode(t, in)[0]
+	k, v := lookup(doc, "resources")
+	if err := newEditor([]byte(in)).replaceValue(k, v, []string{"  requests:"}); err == nil {
+		t.Error("expected an error for a multi-line flow mapping")
+	}
+}
+
+func TestEditorBytes(t *testing.T) {
+	tests := []struct {
+		name  string
+		in    string
+		apply func(e *editor)
+		want  string
+	}{
+		{
+			name:  "no edits",
+			in:    "a: 1\nb: 2\n",
+			apply: func(e *editor) {},
+			want:  "a: 1\nb: 2\n",
+		},
+		{
+			name: "replace keeps the rest of the line",
+			in:   "a: 1 # one\n",
+			apply: func(e *editor) {
+				le := e.edit(1)
+				le.replace, le.from, le.to, le.text = true, 3, 4, "2"
+			},
+			want: "a: 2 # one\n",
+		},
+		{
+			name: "inserts after several lines",
+			in:   "a:\nb:\n",
+			apply: func(e *editor) {
+				e.insertAfter(1, "  x: 1")
+				e.insertAfter(2, "  y: 2", "  z: 3")
+			},
+			want: "a:\n  x: 1\nb:\n  y: 2\n  z: 3\n",
+		},
+		{
+			name: "delete and insert on one line",
+			in:   "a: {}\nb: 1\n",
+			apply: func(e *editor) {
+				e.edit(2).delete = true
+				e.insertAfter(2, "c: 2")
+			},
+			want: "a: {}\nc: 2\n",
+		},
+		{
+			name: "deletes of consecutive lines",
+			in:   "a: 1\nb:\n  c: 2\nd: 3",
+			apply: func(e *editor) {
+				e.edit(2).delete = true
+				e.edit(3).delete = true
+			},
+			want: "a: 1\nd: 3",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newEditor([]byte(tt.in))
+			tt.apply(e)
+			if got := string(e.bytes()); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEndLine(t *testing.T) {
+	in := `a:
+  script: |
+    echo one
+
+    echo two
+
+b:
+  c: 1
+  d: >-
+    folded
+    text
+`
+	doc := decode(t, in)[0]
+	tests := []struct {
+		key  string
+		want int
+	}{
+		{"a", 5},
+		{"b", 11},
+	}
+	e := newEditor([]byte(in))
+	for _, tt := range tests {
+		k, v := lookup(doc, tt.key)
+		if got := e.endLine(v, k.Column-1); got != tt.want {
+			t.Errorf("endLine(%s) = %d, want %d", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestScalarEnd(t *testing.T) {
+	tests := []struct {
+		line   string
+		column int
+		want   string
+	}{
+		{`cpu: 500m`, 5, `500m`},
+		{`cpu: 500m   # comment`, 5, `500m`},
+		{`cpu: "500m" # comment`, 5, `"500m"`},
+		{`cpu: 'a # b' # comment`, 5, `'a # b'`},
+		{`größe: 1Gi # groß`, 7, `1Gi`},
+		{`clé: "déjà" # ü`, 5, `"déjà"`},
+	}
+	for _, tt := range tests {
+		from := byteOffset(tt.line, tt.column)
+		if got := tt.line[from:scalarEnd(tt.line, from)]; got != tt.want {
+			t.Errorf("%q at column %d: got %q, want %q", tt.line, tt.column, got, tt.want)
+		}
+	}
+	if got := byteOffset("größe", 10); got != len("größe") {
+		t.Errorf("byteOffset past the end = %d, want %d", got, len("größe"))
+	}
+	if !bytes.Equal(newEditor([]byte("ü: 1")).bytes(), []byte("ü: 1")) {
+		t.Error("UTF-8 text changed without edits")
+	}
+}
@@ -20,6 +20,7 @@ func PrettyPrintWorkload(w models.WorkLoad) {
 	for _, c := range w.Containers {
 		containers = append(containers, map[string]interface{}{
 			"name":     c.Name,
+			"role":     c.Role,
 			"requests": ResourceListToMap(c.Resources.Request),
 			"limits":   ResourceListToMap(c.Resources.Limits),
 		})
@@ -0,0 +1,84 @@
+package history
+
+import (
+	"math"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// Flap is a container whose recommended request kept going up and down.
+type Flap struct {
+	Cluster   string          `json:"cluster,omitempty"`
+	Namespace string          `json:"namespace"`
+	Kind      string          `json:"kind"`
+	Workload  string          `json:"workload"`
+	Container string          `json:"container"`
+	Resource  v1.ResourceName `json:"resource"`
+	// Reversals counts the changes of direction of the recommendation.
+	Reversals int `json:"reversals"`
+	// Values are the recommended requests, oldest first.
+	Values []string `json:"values"`
+}
+
+// Flapping finds the containers whose recommended CPU or memory request
+// changed direction at least minReversals times. Moves of at most
+// thresholdPercent of the previous value are noise and ignored. records
+// must be grouped by container and ordered by time, as History returns
+// them.
+func Flapping(records []Record, thresholdPercent float64, minReversals int) []Flap {
+	var flaps []Flap
+	for start := 0; start < len(records); {
+		end := start + 1
+		for end < len(records) && containerKey(records[end]) == containerKey(records[start]) {
+			end++
+		}
+		group := records[start:end]
+		start = end
+
+		for _, name := range []v1.ResourceName{v1.ResourceCPU, v1.ResourceMemory} {
+			var values []string
+			var last float64
+			direction, reversals := 0, 0
+			for _, r := range group {
+				q, ok := r.Recommended.Request[name]
+				if !ok {
+					continue
+				}
+				v := q.AsApproximateFloat64()
+				values = append(values, q.String())
+				if len(values) == 1 {
+					last = v
+					continue
+				}
+				if last == 0 || math.Abs(v-last)/last*100 <= thresholdPercent {
+					if last == 0 {
+						last = v
+					}
+					continue
+				}
+				d := 1
+				if v < last {
+					d = -1
+				}
+				if direction != 0 && d != direction {
+					reversals++
+				}
+				direction, last = d, v
+			}
+			if reversals >= minReversals {
+				first := group[0]
+				flaps = append(flaps, Flap{
+					Cluster:   first.Cluster,
+					Namespace: first.Namespace,
+					Kind:      first.Kind,
+					Workload:  first.Workload,
+					Container: first.Container,
+					Resource:  name,
+					Reversals: reversals,
+					Values:    values,
+				})
+			}
+		}
+	}
+	return flaps
+}
@@ -0,0 +1,73 @@
+package history
+
+import (
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// records returns one record per run of container with the given
+// recommended CPU requests, oldest first.
+func records(container string, cpu ...string) []Record {
+	var out []Record
+	for _, c := range cpu {
+		r := Record{Namespace: "shop", Kind: "Deployment", Workload: "api", Container: container}
+		r.Recommended.Request = v1.ResourceList{v1.ResourceCPU: resource.MustParse(c)}
+		out = append(out, r)
+	}
+	return out
+}
+
+func TestFlapping(t *testing.T) {
+	tests := []struct {
+		name         string
+		records      []Record
+		minReversals int
+		want         []string
+		reversals    int
+	}{
+		{"steady growth", records("app", "100m", "200m", "300m"), 1, nil, 0},
+		{"up and down", records("app", "100m", "200m", "100m", "200m"), 2, []string{"100m", "200m", "100m", "200m"}, 2},
+		{"below min reversals", records("app", "100m", "200m", "100m"), 2, nil, 0},
+		{"moves within threshold ignored", records("app", "100m", "105m", "100m", "105m"), 1, nil, 0},
+		{"noise does not reset the direction", records("app", "100m", "200m", "195m", "100m"), 1, []string{"100m", "200m", "195m", "100m"}, 1},
+		{"rise from zero", records("app", "0", "200m", "100m"), 1, nil, 0},
+		{"containers counted apart", append(records("app", "100m", "200m"), records("sidecar", "100m", "50m")...), 1, nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flaps := Flapping(tt.records, 10, tt.minReversals)
+			if tt.want == nil {
+				if len(flaps) != 0 {
+					t.Errorf("flaps = %+v, want none", flaps)
+				}
+				return
+			}
+			if len(flaps) != 1 {
+				t.Fatalf("flaps = %+v, want one", flaps)
+			}
+			f := flaps[0]
+			if f.Container != "app" || f.Resource != v1.ResourceCPU || f.Reversals != tt.reversals {
+				t.Errorf("flap = %+v, want %d cpu reversals of app", f, tt.reversals)
+			}
+			if !reflect.DeepEqual(f.Values, tt.want) {
+				t.Errorf("values = %v, want %v", f.Values, tt.want)
+			}
+		})
+	}
+}
+
+func TestFlappingMemory(t *testing.T) {
+	var rs []Record
+	for _, m := range []string{"256Mi", "512Mi", "256Mi"} {
+		r := Record{Namespace: "shop", Kind: "Deployment", Workload: "api", Container: "app"}
+		r.Recommended.Request = v1.ResourceList{v1.ResourceCPU: resource.MustParse("100m"), v1.ResourceMemory: resource.MustParse(m)}
+		rs = append(rs, r)
+	}
+	flaps := Flapping(rs, 10, 1)
+	if len(flaps) != 1 || flaps[0].Resource != v1.ResourceMemory || flaps[0].Reversals != 1 {
+		t.Errorf("flaps = %+v, want one memory reversal", flaps)
+	}
+}
@@ -0,0 +1,445 @@
+// Package history keeps the reports of past runs in a local bbolt database,
+// so that recommendations can be followed over weeks without querying
+// Prometheus again.
+package history
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tabed23/k8s-resource-tuner/internal/models"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Buckets of the database. Runs and reports are keyed by run ID, which
+// sorts by start time. Records are keyed by container then run ID, so that
+// the history of a workload is one range of keys.
+var (
+	runsBucket    = []byte("runs")
+	reportsBucket = []byte("reports")
+	recordsBucket = []byte("records")
+)
+
+// lockTimeout bounds the wait for a database another run holds open.
+const lockTimeout = 10 * time.Second
+
+// Store is an open history database.
+type Store struct {
+	db *bolt.DB
+}
+
+// Run describes one recorded run.
+type Run struct {
+	ID        string    `json:"id"`
+	Timestamp time.Time `json:"timestamp"`
+	Command   string    `json:"command"`
+	Lookback  string    `json:"lookback,omitempty"`
+	Clusters  []string  `json:"clusters,omitempty"`
+	Workloads int       `json:"workloads"`
+	// Applied lists the WorkloadKey of the workloads the run patched.
+	Applied []string `json:"applied,omitempty"`
+}
+
+// Record is the recommendation for one container in one run.
+type Record struct {
+	RunID       string                `json:"run_id"`
+	Timestamp   time.Time             `json:"timestamp"`
+	Cluster     string                `json:"cluster,omitempty"`
+	Namespace   string                `json:"namespace"`
+	Kind        string                `json:"kind"`
+	Workload    string                `json:"workload"`
+	Container   string                `json:"container"`
+	Current     models.ResourceConfig `json:"current"`
+	Recommended models.ResourceConfig `json:"recommended"`
+	// Patched holds the requests and limits apply set on the container,
+	// which differ from Recommended when the safety gates adjusted them.
+	Patched *models.ResourceConfig `json:"patched,omitempty"`
+	// Usage holds the statistics of the lookback window without the
+	// samples, which stay in the full report of the run.
+	Usage   *models.UsageStats `json:"usage,omitempty"`
+	Applied bool               `json:"applied"`
+}
+
+// Open opens the database at path, creating it and its directory if needed.
+func Open(path string) (*Store, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create history directory: %v", err)
+		}
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: lockTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history %s: %v", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, b := range [][]byte{runsBucket, reportsBucket, recordsBucket} {
+			if _, err := tx.CreateBucketIfNotExists(b); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize history %s: %v", path, err)
+	}
+	return &Store{db: db}, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// WorkloadKey identifies a workload across runs.
+func WorkloadKey(cluster, namespace, kind, name string) string {
+	return cluster + "/" + namespace + "/" + kind + "/" + name
+}
+
+func recordKey(r Record) []byte {
+	return []byte(WorkloadKey(r.Cluster, r.Namespace, r.Kind, r.Workload) + "/" + r.Container + "\x00" + r.RunID)
+}
+
+// Save records the run, its report without the usage samples and a record
+// per container of the report. patched are the entries of the workloads the
+// run patched, with the recommendations as they were applied; their records
+// are marked applied. Saving a run ID again replaces the run.
+func (s *Store) Save(run Run, r models.Report, patched []models.ReportEntry) error {
+	if run.ID == "" {
+		return fmt.Errorf("cannot record a run without ID")
+	}
+	r = withoutSamples(r)
+	run.Workloads = len(r.Entries)
+	run.Applied = nil
+	applied := map[string]models.ReportEntry{}
+	for _, e := range patched {
+		key := WorkloadKey(e.Cluster, e.Workload.Namespace, e.Workload.Kind, e.Workload.Name)
+		run.Applied = append(run.Applied, key)
+		applied[key] = e
+	}
+
+	runData, err := json.Marshal(run)
+	if err != nil {
+		return fmt.Errorf("failed to encode run: %v", err)
+	}
+	reportData, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("failed to encode report: %v", err)
+	}
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(runsBucket).Put([]byte(run.ID), runData); err != nil {
+			return err
+		}
+		if err := tx.Bucket(reportsBucket).Put([]byte(run.ID), reportData); err != nil {
+			return err
+		}
+		records := tx.Bucket(recordsBucket)
+		for _, rec := range Records(run, r) {
+			if e, ok := applied[WorkloadKey(rec.Cluster, rec.Namespace, rec.Kind, rec.Workload)]; ok {
+				rec.Applied = true
+				rec.Patched = patchedResources(e, rec.Container)
+			}
+			data, err := json.Marshal(rec)
+			if err != nil {
+				return err
+			}
+			if err := records.Put(recordKey(rec), data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record run %s: %v", run.ID, err)
+	}
+	return nil
+}
+
+// Records flattens the report into one record per container.
+func Records(run Run, r models.Report) []Record {
+	var records []Record
+	for _, e := range r.Entries {
+		for _, rec := range e.Recommendation {
+			record := Record{
+				RunID:     run.ID,
+				Timestamp: run.Timestamp,
+				Cluster:   e.Cluster,
+				Namespace: e.Workload.Namespace,
+				Kind:      e.Workload.Kind,
+				Workload:  e.Workload.Name,
+				Container: rec.ContainerName,
+				Recommended: models.ResourceConfig{
+					Request: rec.RecommendedRequest.Request,
+					Limits:  rec.RecommendedLimit.Limits,
+				},
+			}
+			for _, c := range e.Workload.Containers {
+				if c.Name == rec.ContainerName {
+					record.Current = c.Resources
+				}
+			}
+			if rec.UsageStats != nil {
+				usage := statistics(*rec.UsageStats)
+				record.Usage = &usage
+			}
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+// patchedResources returns the requests and limits of the container in an
+// entry as it was applied, or nil when the entry has no recommendation for
+// it.
+func patchedResources(e models.ReportEntry, container string) *models.ResourceConfig {
+	for _, rec := range e.Recommendation {
+		if rec.ContainerName == container {
+			return &models.ResourceConfig{Request: rec.RecommendedRequest.Request, Limits: rec.RecommendedLimit.Limits}
+		}
+	}
+	return nil
+}
+
+// statistics drops the samples and series of the usage, which make up most
+// of a report and are only needed to draw its charts.
+func statistics(u models.UsageStats) models.UsageStats {
+	u.CPUSamples, u.MemSamples = nil, nil
+	u.CPUSeries, u.MemSeries = nil, nil
+	return u
+}
+
+// withoutSamples returns a copy of the report with the statistics of the
+// usage only, leaving r untouched.
+func withoutSamples(r models.Report) models.Report {
+	entries := make([]models.ReportEntry, len(r.Entries))
+	for i, e := range r.Entries {
+		stats := make([]models.UsageStats, len(e.Stats))
+		for j, u := range e.Stats {
+			stats[j] = statistics(u)
+		}
+		e.Stats = stats
+		recs := make([]models.Recommendation, len(e.Recommendation))
+		for j, rec := range e.Recommendation {
+			if rec.UsageStats != nil {
+				usage := statistics(*rec.UsageStats)
+				rec.UsageStats = &usage
+			}
+			recs[j] = rec
+		}
+		e.Recommendation = recs
+		entries[i] = e
+	}
+	r.Entries = entries
+	return r
+}
+
+// Prune deletes the runs started before the given time along with their
+// reports and records, and returns how many it deleted.
+func (s *Store) Prune(before time.Time) (int, error) {
+	var pruned int
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		old := map[string]bool{}
+		c := tx.Bucket(runsBucket).Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var run Run
+			if err := json.Unmarshal(v, &run); err != nil {
+				return fmt.Errorf("run %s: %v", k, err)
+			}
+			if run.Timestamp.Before(before) {
+				old[string(k)] = true
+			}
+		}
+		if len(old) == 0 {
+			return nil
+		}
+		for id := range old {
+			if err := tx.Bucket(runsBucket).Delete([]byte(id)); err != nil {
+				return err
+			}
+			if err := tx.Bucket(reportsBucket).Delete([]byte(id)); err != nil {
+				return err
+			}
+		}
+		// Deleting while iterating skips keys, so collect the records first.
+		var keys [][]byte
+		records := tx.Bucket(recordsBucket)
+		err := records.ForEach(func(k, _ []byte) error {
+			if _, runID, ok := strings.Cut(string(k), "\x00"); ok && old[runID] {
+				keys = append(keys, append([]byte(nil), k...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range keys {
+			if err := records.Delete(k); err != nil {
+				return err
+			}
+		}
+		pruned = len(old)
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune history: %v", err)
+	}
+	return pruned, nil
+}
+
+// Runs returns the runs started at or after since, oldest first.
+func (s *Store) Runs(since time.Time) ([]Run, error) {
+	var runs []Run
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(runsBucket).Cursor()
+		for k, v := c.Seek([]byte(since.UTC().Format("20060102T150405Z"))); k != nil; k, v = c.Next() {
+			var run Run
+			if err := json.Unmarshal(v, &run); err != nil {
+				return fmt.Errorf("run %s: %v", k, err)
+			}
+			if !run.Timestamp.Before(since) {
+				runs = append(runs, run)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read runs: %v", err)
+	}
+	return runs, nil
+}
+
+// Report returns the full report of a run.
+func (s *Store) Report(runID string) (models.Report, error) {
+	var r models.Report
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(reportsBucket).Get([]byte(runID))
+		if data == nil {
+			return fmt.Errorf("run %s is not in the history", runID)
+		}
+		return json.Unmarshal(data, &r)
+	})
+	return r, err
+}
+
+// Filter selects the records of History. Empty fields match everything.
+type Filter struct {
+	Since      time.Time
+	Namespaces []string
+	Kind       string
+	Workload   string
+}
+
+func (f Filter) matches(r Record) bool {
+	if r.Timestamp.Before(f.Since) ||
+		(f.Kind != "" && !strings.EqualFold(f.Kind, r.Kind)) ||
+		(f.Workload != "" && f.Workload != r.Workload) {
+		return false
+	}
+	if len(f.Namespaces) == 0 {
+		return true
+	}
+	for _, ns := range f.Namespaces {
+		if ns == r.Namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// History returns the records matching the filter, grouped by container
+// and oldest first within a container. When the filter names the kind,
+// workload and namespaces, only the key ranges of that workload in each
+// recorded cluster are read.
+func (s *Store) History(f Filter) ([]Record, error) {
+	var records []Record
+	add := func(k, v []byte) error {
+		var r Record
+		if err := json.Unmarshal(v, &r); err != nil {
+			return fmt.Errorf("record %q: %v", k, err)
+		}
+		if f.matches(r) {
+			records = append(records, r)
+		}
+		return nil
+	}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		prefixes, err := f.prefixes(tx)
+		if err != nil {
+			return err
+		}
+		if prefixes == nil {
+			return tx.Bucket(recordsBucket).ForEach(add)
+		}
+		c := tx.Bucket(recordsBucket).Cursor()
+		for _, prefix := range prefixes {
+			for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+				if err := add(k, v); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history: %v", err)
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		a, b := containerKey(records[i]), containerKey(records[j])
+		if a != b {
+			return a < b
+		}
+		return records[i].Timestamp.Before(records[j].Timestamp)
+	})
+	return records, nil
+}
+
+// prefixes returns the record key prefixes of the workload of the filter in
+// every cluster of the recorded runs, or nil when the filter does not name a
+// single workload per namespace.
+func (f Filter) prefixes(tx *bolt.Tx) ([][]byte, error) {
+	kind := canonicalKind(f.Kind)
+	if kind == "" || f.Workload == "" || len(f.Namespaces) == 0 {
+		return nil, nil
+	}
+	clusters := map[string]bool{"": true}
+	err := tx.Bucket(runsBucket).ForEach(func(k, v []byte) error {
+		var run Run
+		if err := json.Unmarshal(v, &run); err != nil {
+			return fmt.Errorf("run %s: %v", k, err)
+		}
+		for _, c := range run.Clusters {
+			clusters[c] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	var prefixes [][]byte
+	for c := range clusters {
+		for _, ns := range f.Namespaces {
+			prefixes = append(prefixes, []byte(WorkloadKey(c, ns, kind, f.Workload)+"/"))
+		}
+	}
+	return prefixes, nil
+}
+
+// canonicalKind returns the spelling of a workload kind used in the keys,
+// "" for an unknown kind.
+func canonicalKind(kind string) string {
+	for _, k := range []string{models.KindDeployment, models.KindStatefulSet, models.KindDaemonSet, models.KindJob, models.KindCronJob} {
+		if strings.EqualFold(k, kind) {
+			return k
+		}
+	}
+	return ""
+}
+
+func containerKey(r Record) string {
+	return WorkloadKey(r.Cluster, r.Namespace, r.Kind, r.Workload) + "/" + r.Container
+}
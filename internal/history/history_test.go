@@ -0,0 +1,71 @@
+package history
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/tabed23/k8s-resource-tuner/internal/models"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func reportEntry(name, cpu string) models.ReportEntry {
+	return models.ReportEntry{
+		Workload: models.WorkLoad{Namespace: "shop", Name: name, Kind: models.KindDeployment},
+		Recommendation: []models.Recommendation{{
+			ContainerName:      "app",
+			RecommendedRequest: models.ResourceConfig{Request: v1.ResourceList{v1.ResourceCPU: resource.MustParse(cpu)}},
+		}},
+	}
+}
+
+func TestSavePatched(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	run := Run{ID: "run-1", Timestamp: time.Date(2026, 10, 1, 8, 0, 0, 0, time.UTC), Command: "apply"}
+	r := models.Report{Entries: []models.ReportEntry{reportEntry("api", "100m"), reportEntry("web", "100m")}}
+	// The safety gates capped the api change before it was patched.
+	if err := store.Save(run, r, []models.ReportEntry{reportEntry("api", "500m")}); err != nil {
+		t.Fatal(err)
+	}
+
+	runs, err := store.Runs(time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 1 || !reflect.DeepEqual(runs[0].Applied, []string{WorkloadKey("", "shop", models.KindDeployment, "api")}) {
+		t.Errorf("runs = %+v, want api applied", runs)
+	}
+	records, err := store.History(Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("records = %+v, want 2", records)
+	}
+	for _, rec := range records {
+		recommended := rec.Recommended.Request[v1.ResourceCPU]
+		if recommended.String() != "100m" {
+			t.Errorf("%s: recommended = %s, want the raw 100m", rec.Workload, recommended.String())
+		}
+		switch rec.Workload {
+		case "api":
+			if !rec.Applied || rec.Patched == nil {
+				t.Fatalf("api: applied %v, patched %v", rec.Applied, rec.Patched)
+			}
+			if q := rec.Patched.Request[v1.ResourceCPU]; q.String() != "500m" {
+				t.Errorf("api: patched = %s, want 500m", q.String())
+			}
+		case "web":
+			if rec.Applied || rec.Patched != nil {
+				t.Errorf("web: applied %v, patched %v, want neither", rec.Applied, rec.Patched)
+			}
+		}
+	}
+}
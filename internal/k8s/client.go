@@ -1,21 +1,74 @@
 package k8s
 
 import (
+	"errors"
+	"fmt"
+	"os"
+
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 )
 
-// Change it to in Cluster whe deploy
-// config, err := rest.InClusterConfig()
-// 	if err != nil {
-// 		return nil, err
-// 	}'
+// ClientOptions select and tune the cluster connection. The zero value uses
+// the in-cluster service account when running as a pod, and otherwise
+// $KUBECONFIG or ~/.kube/config with its current context.
+type ClientOptions struct {
+	Kubeconfig        string
+	Context           string
+	ImpersonateUser   string
+	ImpersonateGroups []string
+	QPS               float32
+	Burst             int
+}
 
+// BuildConfig resolves the REST config described by opts.
+func BuildConfig(opts ClientOptions) (*rest.Config, error) {
+	config, err := loadConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.ImpersonateUser != "" || len(opts.ImpersonateGroups) > 0 {
+		config.Impersonate = rest.ImpersonationConfig{
+			UserName: opts.ImpersonateUser,
+			Groups:   opts.ImpersonateGroups,
+		}
+	}
+	if opts.QPS > 0 {
+		config.QPS = opts.QPS
+	}
+	if opts.Burst > 0 {
+		config.Burst = opts.Burst
+	}
+	return config, nil
+}
+
+func loadConfig(opts ClientOptions) (*rest.Config, error) {
+	explicit := opts.Kubeconfig != "" || opts.Context != "" || os.Getenv(clientcmd.RecommendedConfigPathEnvVar) != ""
+	if !explicit {
+		config, err := rest.InClusterConfig()
+		if err == nil {
+			return config, nil
+		}
+		if !errors.Is(err, rest.ErrNotInCluster) {
+			return nil, fmt.Errorf("failed to load in-cluster config: %v", err)
+		}
+	}
 
-func InitKubeClient() (*kubernetes.Clientset, error) {
-	kubeconfig := ""
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	if opts.Kubeconfig != "" {
+		rules.ExplicitPath = opts.Kubeconfig
+	}
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: opts.Context}
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides).ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load kubeconfig: %v", err)
+	}
+	return config, nil
+}
 
-	config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+func InitKubeClient(opts ClientOptions) (*kubernetes.Clientset, error) {
+	config, err := BuildConfig(opts)
 	if err != nil {
 		return nil, err
 	}
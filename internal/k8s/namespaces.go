@@ -0,0 +1,88 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// SystemNamespaces are skipped during discovery unless IncludeSystem is set.
+var SystemNamespaces = []string{"kube-system", "kube-public", "kube-node-lease"}
+
+// NamespaceFilter selects namespaces during cluster-wide discovery. Include
+// and Exclude entries are glob patterns ("team-*"), or regular expressions
+// when prefixed with "re:" ("re:^payments-(eu|us)$"). An empty Include list
+// matches every namespace.
+type NamespaceFilter struct {
+	Include       []string
+	Exclude       []string
+	LabelSelector string
+	IncludeSystem bool
+}
+
+// DiscoverNamespaces lists the namespaces of the cluster that pass the filter.
+func DiscoverNamespaces(clientset *kubernetes.Clientset, filter NamespaceFilter) ([]string, error) {
+	list, err := clientset.CoreV1().Namespaces().List(context.TODO(), metav1.ListOptions{
+		LabelSelector: filter.LabelSelector,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list namespaces: %v", err)
+	}
+	var namespaces []string
+	for _, ns := range list.Items {
+		ok, err := filter.Matches(ns.Name)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			namespaces = append(namespaces, ns.Name)
+		}
+	}
+	sort.Strings(namespaces)
+	return namespaces, nil
+}
+
+// Matches reports whether a namespace name passes the include, exclude and
+// system namespace rules. The label selector is applied by the API server.
+func (f NamespaceFilter) Matches(namespace string) (bool, error) {
+	if !f.IncludeSystem {
+		for _, system := range SystemNamespaces {
+			if namespace == system {
+				return false, nil
+			}
+		}
+	}
+	excluded, err := matchAny(f.Exclude, namespace)
+	if err != nil || excluded {
+		return false, err
+	}
+	if len(f.Include) == 0 {
+		return true, nil
+	}
+	return matchAny(f.Include, namespace)
+}
+
+func matchAny(patterns []string, name string) (bool, error) {
+	for _, p := range patterns {
+		var ok bool
+		var err error
+		if expr, isRegex := strings.CutPrefix(p, "re:"); isRegex {
+			ok, err = regexp.MatchString(expr, name)
+		} else {
+			ok, err = path.Match(p, name)
+		}
+		if err != nil {
+			return false, fmt.Errorf("invalid namespace pattern %q: %v", p, err)
+		}
+		if ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,22 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// NodeLabels returns the labels of every node of the cluster by node name.
+func NodeLabels(clientset *kubernetes.Clientset) (map[string]map[string]string, error) {
+	list, err := clientset.CoreV1().Nodes().List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %v", err)
+	}
+	labels := make(map[string]map[string]string, len(list.Items))
+	for _, n := range list.Items {
+		labels[n.Name] = n.Labels
+	}
+	return labels, nil
+}
@@ -0,0 +1,148 @@
+package k8s
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/tabed23/k8s-resource-tuner/internal/models"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// generatedSuffix matches the random suffix the API server appends to
+// generateName, which ReplicaSets, DaemonSets and Jobs use for their pods.
+const generatedSuffix = "-[a-z0-9]{5}"
+
+type ownerKey struct {
+	kind string
+	name string
+}
+
+// OwnerIndex resolves the pods of a namespace to their top-level workload by
+// following ownerReferences: Pod -> ReplicaSet -> Deployment, Pod -> Job ->
+// CronJob, and Pod -> StatefulSet/DaemonSet/Job directly.
+type OwnerIndex struct {
+	// intermediates holds the ReplicaSets and Jobs owned by each workload.
+	intermediates map[ownerKey][]string
+	pods          map[ownerKey][]string
+	// nodes maps a pod to the node it is scheduled on.
+	nodes map[string]string
+	// finished holds the pods that succeeded, failed or were evicted.
+	finished map[string]bool
+}
+
+func NewOwnerIndex(clientset *kubernetes.Clientset, namespace string) (*OwnerIndex, error) {
+	replicaSets, err := clientset.AppsV1().ReplicaSets(namespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	jobs, err := clientset.BatchV1().Jobs(namespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	pods, err := clientset.CoreV1().Pods(namespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	idx := &OwnerIndex{
+		intermediates: map[ownerKey][]string{},
+		pods:          map[ownerKey][]string{},
+		nodes:         map[string]string{},
+		finished:      map[string]bool{},
+	}
+	// parents maps an intermediate controller to the workload that owns it.
+	parents := map[ownerKey]ownerKey{}
+	for _, rs := range replicaSets.Items {
+		if ref := metav1.GetControllerOf(&rs); ref != nil && ref.Kind == models.KindDeployment {
+			owner := ownerKey{models.KindDeployment, ref.Name}
+			parents[ownerKey{"ReplicaSet", rs.Name}] = owner
+			idx.intermediates[owner] = append(idx.intermediates[owner], rs.Name)
+		}
+	}
+	for _, j := range jobs.Items {
+		if ref := metav1.GetControllerOf(&j); ref != nil && ref.Kind == models.KindCronJob {
+			owner := ownerKey{models.KindCronJob, ref.Name}
+			parents[ownerKey{models.KindJob, j.Name}] = owner
+			idx.intermediates[owner] = append(idx.intermediates[owner], j.Name)
+		}
+	}
+	for _, p := range pods.Items {
+		ref := metav1.GetControllerOf(&p)
+		if ref == nil {
+			continue
+		}
+		owner := ownerKey{ref.Kind, ref.Name}
+		if parent, ok := parents[owner]; ok {
+			owner = parent
+		}
+		idx.pods[owner] = append(idx.pods[owner], p.Name)
+		if p.Spec.NodeName != "" {
+			idx.nodes[p.Name] = p.Spec.NodeName
+		}
+		if p.Status.Phase == v1.PodSucceeded || p.Status.Phase == v1.PodFailed {
+			idx.finished[p.Name] = true
+		}
+	}
+	return idx, nil
+}
+
+// Pods returns the names of the pending and running pods of the workload.
+// Finished pods, evicted ones included, hold no resources and do not count
+// as replicas.
+func (idx *OwnerIndex) Pods(w models.WorkLoad) []string {
+	var pods []string
+	for _, p := range idx.allPods(w) {
+		if !idx.finished[p] {
+			pods = append(pods, p)
+		}
+	}
+	return pods
+}
+
+// allPods returns the names of the pods owned by the workload, finished
+// ones included.
+func (idx *OwnerIndex) allPods(w models.WorkLoad) []string {
+	pods := append([]string(nil), idx.pods[ownerKey{w.Kind, w.Name}]...)
+	sort.Strings(pods)
+	return pods
+}
+
+// Nodes returns the node of every pod returned by Pods, in the same order,
+// "" for pods not scheduled yet.
+func (idx *OwnerIndex) Nodes(w models.WorkLoad) []string {
+	pods := idx.Pods(w)
+	nodes := make([]string, len(pods))
+	for i, p := range pods {
+		nodes[i] = idx.nodes[p]
+	}
+	return nodes
+}
+
+// PodPattern returns an anchored regular expression matching the pods of the
+// workload. Besides the pods that exist now, finished ones included, it
+// covers pods of the workload's ReplicaSets and Jobs that may have been
+// replaced within the lookback window. It returns "" when nothing in the
+// namespace belongs to the workload.
+func (idx *OwnerIndex) PodPattern(w models.WorkLoad) string {
+	var alternatives []string
+	for _, name := range idx.allPods(w) {
+		alternatives = append(alternatives, regexp.QuoteMeta(name))
+	}
+	for _, name := range idx.intermediates[ownerKey{w.Kind, w.Name}] {
+		alternatives = append(alternatives, regexp.QuoteMeta(name)+generatedSuffix)
+	}
+	switch w.Kind {
+	case models.KindStatefulSet:
+		alternatives = append(alternatives, regexp.QuoteMeta(w.Name)+"-[0-9]+")
+	case models.KindDaemonSet, models.KindJob:
+		alternatives = append(alternatives, regexp.QuoteMeta(w.Name)+generatedSuffix)
+	}
+	if len(alternatives) == 0 {
+		return ""
+	}
+	return "^(?:" + strings.Join(alternatives, "|") + ")$"
+}
@@ -0,0 +1,38 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/tabed23/k8s-resource-tuner/internal/models"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+// PatchResources applies a strategic merge patch to the workload. With
+// dryRun set the API server validates the patch without persisting it.
+func PatchResources(clientset *kubernetes.Clientset, w models.WorkLoad, patch []byte, dryRun bool) error {
+	opts := metav1.PatchOptions{FieldManager: "k8s-resource-tuner"}
+	if dryRun {
+		opts.DryRun = []string{metav1.DryRunAll}
+	}
+	ctx := context.TODO()
+	var err error
+	switch w.Kind {
+	case models.KindDeployment:
+		_, err = clientset.AppsV1().Deployments(w.Namespace).Patch(ctx, w.Name, types.StrategicMergePatchType, patch, opts)
+	case models.KindStatefulSet:
+		_, err = clientset.AppsV1().StatefulSets(w.Namespace).Patch(ctx, w.Name, types.StrategicMergePatchType, patch, opts)
+	case models.KindDaemonSet:
+		_, err = clientset.AppsV1().DaemonSets(w.Namespace).Patch(ctx, w.Name, types.StrategicMergePatchType, patch, opts)
+	case models.KindCronJob:
+		_, err = clientset.BatchV1().CronJobs(w.Namespace).Patch(ctx, w.Name, types.StrategicMergePatchType, patch, opts)
+	default:
+		return fmt.Errorf("patching %s is not supported", w.Kind)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to patch %s %s/%s: %v", w.Kind, w.Namespace, w.Name, err)
+	}
+	return nil
+}
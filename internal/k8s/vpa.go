@@ -0,0 +1,78 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/kubernetes"
+)
+
+// VPA is the part of a VerticalPodAutoscaler the tuner compares its
+// recommendations with.
+type VPA struct {
+	Name       string
+	TargetKind string
+	TargetName string
+	// Targets are the status recommendations by container name.
+	Targets map[string]v1.ResourceList
+}
+
+// vpaObject mirrors the fields of autoscaling.k8s.io/v1
+// VerticalPodAutoscaler read by ListVPAs.
+type vpaObject struct {
+	Metadata struct {
+		Name string `json:"name"`
+	} `json:"metadata"`
+	Spec struct {
+		TargetRef struct {
+			Kind string `json:"kind"`
+			Name string `json:"name"`
+		} `json:"targetRef"`
+	} `json:"spec"`
+	Status struct {
+		Recommendation struct {
+			ContainerRecommendations []struct {
+				ContainerName string          `json:"containerName"`
+				Target        v1.ResourceList `json:"target"`
+			} `json:"containerRecommendations"`
+		} `json:"recommendation"`
+	} `json:"status"`
+}
+
+// ListVPAs lists the VerticalPodAutoscalers of the namespace. It returns
+// nothing when the VPA custom resource is not installed.
+func ListVPAs(clientset *kubernetes.Clientset, ns string) ([]VPA, error) {
+	data, err := clientset.Discovery().RESTClient().Get().
+		AbsPath("/apis/autoscaling.k8s.io/v1/namespaces", ns, "verticalpodautoscalers").
+		DoRaw(context.TODO())
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list VerticalPodAutoscalers: %v", err)
+	}
+	var list struct {
+		Items []vpaObject `json:"items"`
+	}
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("failed to decode VerticalPodAutoscalers: %v", err)
+	}
+
+	vpas := make([]VPA, 0, len(list.Items))
+	for _, item := range list.Items {
+		vpa := VPA{
+			Name:       item.Metadata.Name,
+			TargetKind: item.Spec.TargetRef.Kind,
+			TargetName: item.Spec.TargetRef.Name,
+			Targets:    map[string]v1.ResourceList{},
+		}
+		for _, rec := range item.Status.Recommendation.ContainerRecommendations {
+			vpa.Targets[rec.ContainerName] = rec.Target
+		}
+		vpas = append(vpas, vpa)
+	}
+	return vpas, nil
+}
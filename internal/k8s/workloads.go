@@ -2,38 +2,166 @@ package k8s
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/tabed23/k8s-resource-tuner/internal/models"
+	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/client-go/kubernetes"
 )
 
-func ListDeployments(clientset *kubernetes.Clientset, namespaces string) ([]models.WorkLoad, error) {
+// WorkloadFilter scopes the workloads of a namespace. Both selectors use
+// the label selector syntax, e.g. "app.kubernetes.io/part-of=checkout" or
+// "resource-tuner/owner in (payments,checkout)", and match everything when
+// empty.
+type WorkloadFilter struct {
+	LabelSelector string
+	// AnnotationSelector is matched against the workload's annotations on
+	// the client, the API server only filters on labels.
+	AnnotationSelector string
+}
+
+// ListWorkloads returns every built-in controller kind in the namespace:
+// Deployments, StatefulSets, DaemonSets, Jobs and CronJobs, that pass the
+// filter.
+func ListWorkloads(clientset *kubernetes.Clientset, namespace string, filter WorkloadFilter) ([]models.WorkLoad, error) {
+	annotations, err := labels.Parse(filter.AnnotationSelector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid annotation selector %q: %v", filter.AnnotationSelector, err)
+	}
+	listers := []func(*kubernetes.Clientset, string, string) ([]models.WorkLoad, error){
+		ListDeployments,
+		ListStatefulSets,
+		ListDaemonSets,
+		ListJobs,
+		ListCronJobs,
+	}
+	var workloads []models.WorkLoad
+	for _, list := range listers {
+		list, err := list(clientset, namespace, filter.LabelSelector)
+		if err != nil {
+			return nil, err
+		}
+		for _, w := range list {
+			if annotations.Matches(labels.Set(w.Annotations)) {
+				workloads = append(workloads, w)
+			}
+		}
+	}
+	return workloads, nil
+}
+
+func ListDeployments(clientset *kubernetes.Clientset, namespaces string, selector string) ([]models.WorkLoad, error) {
 	deployClient := clientset.AppsV1().Deployments(namespaces)
-	deployments, err := deployClient.List(context.TODO(), metav1.ListOptions{})
+	deployments, err := deployClient.List(context.TODO(), metav1.ListOptions{LabelSelector: selector})
 	if err != nil {
 		return nil, err
 	}
 	var workloads []models.WorkLoad
 	for _, d := range deployments.Items {
-		var containers []models.ContainerSpec
-		for _, c := range d.Spec.Template.Spec.Containers {
-			containers = append(containers, models.ContainerSpec{
-				Name: c.Name,
-				Resources: models.ResourceConfig{
-					Request: c.Resources.Requests,
-					Limits:  c.Resources.Limits,
-				},
-			})
-		}
-		workload := models.WorkLoad{
-			Namespace:  d.Namespace,
-			Name:       d.Name,
-			Kind:       "Deployment",
-			Containers: containers,
-			Labels:     d.Labels,
+		workloads = append(workloads, newWorkload(d.ObjectMeta, models.KindDeployment, d.Spec.Template.Spec))
+	}
+	return workloads, nil
+}
+
+func ListStatefulSets(clientset *kubernetes.Clientset, namespace string, selector string) ([]models.WorkLoad, error) {
+	statefulSets, err := clientset.AppsV1().StatefulSets(namespace).List(context.TODO(), metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, err
+	}
+	var workloads []models.WorkLoad
+	for _, s := range statefulSets.Items {
+		workloads = append(workloads, newWorkload(s.ObjectMeta, models.KindStatefulSet, s.Spec.Template.Spec))
+	}
+	return workloads, nil
+}
+
+func ListDaemonSets(clientset *kubernetes.Clientset, namespace string, selector string) ([]models.WorkLoad, error) {
+	daemonSets, err := clientset.AppsV1().DaemonSets(namespace).List(context.TODO(), metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, err
+	}
+	var workloads []models.WorkLoad
+	for _, d := range daemonSets.Items {
+		workloads = append(workloads, newWorkload(d.ObjectMeta, models.KindDaemonSet, d.Spec.Template.Spec))
+	}
+	return workloads, nil
+}
+
+// ListJobs returns standalone Jobs. Jobs created by a CronJob are skipped,
+// they are analyzed through their parent CronJob instead.
+func ListJobs(clientset *kubernetes.Clientset, namespace string, selector string) ([]models.WorkLoad, error) {
+	jobs, err := clientset.BatchV1().Jobs(namespace).List(context.TODO(), metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, err
+	}
+	var workloads []models.WorkLoad
+	for _, j := range jobs.Items {
+		if ownedBy(j.ObjectMeta, models.KindCronJob) {
+			continue
 		}
-		workloads = append(workloads, workload)
+		workloads = append(workloads, newWorkload(j.ObjectMeta, models.KindJob, j.Spec.Template.Spec))
+	}
+	return workloads, nil
+}
+
+func ListCronJobs(clientset *kubernetes.Clientset, namespace string, selector string) ([]models.WorkLoad, error) {
+	cronJobs, err := clientset.BatchV1().CronJobs(namespace).List(context.TODO(), metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, err
+	}
+	var workloads []models.WorkLoad
+	for _, c := range cronJobs.Items {
+		workloads = append(workloads, newWorkload(c.ObjectMeta, models.KindCronJob, c.Spec.JobTemplate.Spec.Template.Spec))
 	}
 	return workloads, nil
 }
+
+func newWorkload(meta metav1.ObjectMeta, kind string, spec v1.PodSpec) models.WorkLoad {
+	return models.WorkLoad{
+		Namespace:   meta.Namespace,
+		Name:        meta.Name,
+		Kind:        kind,
+		Containers:  containersFromPodSpec(spec),
+		Labels:      meta.Labels,
+		Annotations: meta.Annotations,
+	}
+}
+
+func containersFromPodSpec(spec v1.PodSpec) []models.ContainerSpec {
+	var containers []models.ContainerSpec
+	for _, c := range spec.Containers {
+		containers = append(containers, newContainerSpec(c, models.RoleApp))
+	}
+	// Init containers keep their declared order, the effective pod request
+	// depends on which sidecars are already running when each one starts.
+	for _, c := range spec.InitContainers {
+		role := models.RoleInit
+		if c.RestartPolicy != nil && *c.RestartPolicy == v1.ContainerRestartPolicyAlways {
+			role = models.RoleSidecar
+		}
+		containers = append(containers, newContainerSpec(c, role))
+	}
+	return containers
+}
+
+func newContainerSpec(c v1.Container, role models.ContainerRole) models.ContainerSpec {
+	return models.ContainerSpec{
+		Name: c.Name,
+		Role: role,
+		Resources: models.ResourceConfig{
+			Request: c.Resources.Requests,
+			Limits:  c.Resources.Limits,
+		},
+	}
+}
+
+func ownedBy(meta metav1.ObjectMeta, kind string) bool {
+	for _, ref := range meta.OwnerReferences {
+		if ref.Kind == kind {
+			return true
+		}
+	}
+	return false
+}
@@ -1,20 +1,73 @@
 package models
 
 import (
+    "strconv"
     "time"
     v1 "k8s.io/api/core/v1"
 )
 
+const (
+    KindDeployment  = "Deployment"
+    KindStatefulSet = "StatefulSet"
+    KindDaemonSet   = "DaemonSet"
+    KindJob         = "Job"
+    KindCronJob     = "CronJob"
+)
+
+// Annotations workload owners can set to steer the tuner without touching
+// its configuration.
+const (
+    AnnotationIgnore = "resource-tuner/ignore" // "true" skips the workload
+    AnnotationPolicy = "resource-tuner/policy" // name of the recommendation policy
+)
+
+// Annotations set by apply on the workloads it patches, read back by
+// rollback. AnnotationPrevious holds the JSON encoded resources, by container
+// name, the workload had before it was patched.
+const (
+    AnnotationPrevious  = "resource-tuner/previous-resources"
+    AnnotationAppliedAt = "resource-tuner/applied-at" // RFC 3339 time of the patch
+    AnnotationApplyRun  = "resource-tuner/apply-run"  // Report.RunID of the apply run
+)
+
 type WorkLoad struct {
-    Namespace  string            `json:"namespace"`
-    Name       string            `json:"name"`
-    Kind       string            `json:"kind"`
-    Containers []ContainerSpec   `json:"containers"`
-    Labels     map[string]string `json:"labels"`
+    Namespace   string            `json:"namespace"`
+    Name        string            `json:"name"`
+    Kind        string            `json:"kind"`
+    Containers  []ContainerSpec   `json:"containers"`
+    Labels      map[string]string `json:"labels"`
+    Annotations map[string]string `json:"annotations,omitempty"`
+    Pods        []string          `json:"pods,omitempty"`
+    // Nodes holds the node of each of the Pods.
+    Nodes       []string          `json:"nodes,omitempty"`
+    PodPattern  string            `json:"pod_pattern,omitempty"`
+}
+
+// IsBatch reports whether the workload runs to completion, in which case
+// usage only exists while its pods are running.
+func (w WorkLoad) IsBatch() bool {
+    return w.Kind == KindJob || w.Kind == KindCronJob
 }
 
+// Ignored reports whether the workload opted out of analysis.
+func (w WorkLoad) Ignored() bool {
+    ignore, _ := strconv.ParseBool(w.Annotations[AnnotationIgnore])
+    return ignore
+}
+
+// ContainerRole tells how a container participates in the pod lifecycle,
+// which decides how its requests count towards the effective pod request.
+type ContainerRole string
+
+const (
+    RoleApp     ContainerRole = "app"
+    RoleInit    ContainerRole = "init"
+    RoleSidecar ContainerRole = "sidecar" // init container with restartPolicy: Always
+)
+
 type ContainerSpec struct {
     Name      string         `json:"name"`
+    Role      ContainerRole  `json:"role"`
     Resources ResourceConfig `json:"resources"`
 }
 
@@ -29,10 +82,20 @@ type Usage struct {
     Memory    float64   `json:"memory"`
 }
 
+// Point is one value of a time series.
+type Point struct {
+    Timestamp time.Time `json:"timestamp"`
+    Value     float64   `json:"value"`
+}
+
 type UsageStats struct {
     ContainerName string    `json:"container_name"`
     CPUSamples    []float64 `json:"cpu_samples"`
     MemSamples    []float64 `json:"mem_samples"`
+    // CPUSeries and MemSeries hold the highest value across the pods at
+    // each step of the lookback window, for the charts.
+    CPUSeries     []Point   `json:"cpu_series,omitempty"`
+    MemSeries     []Point   `json:"mem_series,omitempty"`
     CPUAvg        float64   `json:"cpu_avg"`
     CPUP95        float64   `json:"cpu_p95"`
     CPUP99        float64   `json:"cpu_p99"`
@@ -49,18 +112,128 @@ type Recommendation struct {
     RecommendedLimit   ResourceConfig `json:"recommended_limit"`
     Reason             string         `json:"reason"`
     UsageStats         *UsageStats    `json:"usage_stats,omitempty"`  // Add UsageStats here
+    // Violations lists how the current spec breaks the policy's standard.
+    Violations         []string       `json:"violations,omitempty"`
+    // VPATarget is the target recommended by an existing
+    // VerticalPodAutoscaler of the workload, for comparison.
+    VPATarget          v1.ResourceList `json:"vpa_target,omitempty"`
+    // Cost is the monthly cost of the container's requests over all the
+    // replicas of the workload, set when pricing is configured.
+    Cost               *Cost          `json:"monthly_cost,omitempty"`
+}
+
+// Cost compares the monthly cost of the current requests with that of the
+// recommended ones.
+type Cost struct {
+    Currency    string  `json:"currency"`
+    Current     float64 `json:"current"`
+    Recommended float64 `json:"recommended"`
+    Savings     float64 `json:"savings"`
 }
 
 type Report struct {
-    Timestamp time.Time     `json:"timestamp"`
-    Entries   []ReportEntry `json:"entries"`
-    Summary   string        `json:"summary"`
+    RunID       string              `json:"run_id,omitempty"`
+    Timestamp   time.Time           `json:"timestamp"`
+    Lookback    string              `json:"lookback,omitempty"`
+    Clusters    []string            `json:"clusters,omitempty"`
+    Entries     []ReportEntry       `json:"entries"`
+    Comparisons []ClusterComparison `json:"comparisons,omitempty"`
+    Summary     Summary             `json:"summary"`
+}
+
+// Summary totals the requested and recommended resources of a report. CPU
+// is in cores and memory in GiB, both summed over the replicas of every
+// workload.
+type Summary struct {
+    Total      Totals           `json:"total"`
+    Clusters   []ScopeTotals    `json:"clusters,omitempty"`
+    Namespaces []ScopeTotals    `json:"namespaces,omitempty"`
+    // Teams and Currency are set when pricing is configured.
+    Teams      []ScopeTotals    `json:"teams,omitempty"`
+    Currency   string           `json:"currency,omitempty"`
+    // TopWasteful and TopAtRisk rank the workloads whose requests are the
+    // furthest above, respectively below, the recommendation.
+    TopWasteful []WorkloadTotals `json:"top_wasteful,omitempty"`
+    TopAtRisk   []WorkloadTotals `json:"top_at_risk,omitempty"`
+}
+
+type Totals struct {
+    Containers        int     `json:"containers"`
+    OverProvisioned   int     `json:"over_provisioned"`
+    UnderProvisioned  int     `json:"under_provisioned"`
+    CPURequested      float64 `json:"cpu_requested_cores"`
+    CPURecommended    float64 `json:"cpu_recommended_cores"`
+    MemoryRequested   float64 `json:"memory_requested_gib"`
+    MemoryRecommended float64 `json:"memory_recommended_gib"`
+    // Monthly cost of the current and recommended requests.
+    CurrentCost       float64 `json:"current_monthly_cost,omitempty"`
+    RecommendedCost   float64 `json:"recommended_monthly_cost,omitempty"`
+    Savings           float64 `json:"monthly_savings,omitempty"`
+}
+
+// ScopeTotals are the totals of a cluster, of a namespace when Namespace
+// is set, or of the workloads of a team.
+type ScopeTotals struct {
+    Cluster   string `json:"cluster,omitempty"`
+    Namespace string `json:"namespace,omitempty"`
+    Team      string `json:"team,omitempty"`
+    Totals
+}
+
+type WorkloadTotals struct {
+    Cluster   string `json:"cluster,omitempty"`
+    Namespace string `json:"namespace"`
+    Kind      string `json:"kind"`
+    Name      string `json:"name"`
+    Totals
 }
 
 type ReportEntry struct {
+    Cluster        string           `json:"cluster,omitempty"`
     Workload       WorkLoad         `json:"workload"`
     Stats          []UsageStats     `json:"stats"`
     Recommendation []Recommendation `json:"recommendations"`
+    // Effective pod-level requests as computed by the scheduler from app,
+    // init and sidecar containers.
+    CurrentPodRequest     v1.ResourceList `json:"current_pod_request,omitempty"`
+    RecommendedPodRequest v1.ResourceList `json:"recommended_pod_request,omitempty"`
+    // Cost sums the cost of the containers, Team is the value of the
+    // workload's team label. Both are set when pricing is configured.
+    Cost *Cost  `json:"monthly_cost,omitempty"`
+    Team string `json:"team,omitempty"`
+    // Replicas is the average number of pods a Job or CronJob ran over the
+    // lookback window, 0 when it did not run.
+    Replicas float64 `json:"replicas,omitempty"`
+}
+
+// ReplicaCount returns the number of pods the requests of the entry count
+// for: the pending and running pods, at least one, or for Jobs and CronJobs
+// the average number of pods running over the lookback window, so that they
+// are priced for the time they actually run.
+func (e ReportEntry) ReplicaCount() float64 {
+    if e.Workload.IsBatch() {
+        return e.Replicas
+    }
+    if len(e.Workload.Pods) == 0 {
+        return 1
+    }
+    return float64(len(e.Workload.Pods))
+}
+
+// ClusterComparison lines up the recommendations for the same workload
+// container across the clusters it runs in.
+type ClusterComparison struct {
+    Namespace string                  `json:"namespace"`
+    Kind      string                  `json:"kind"`
+    Name      string                  `json:"name"`
+    Container string                  `json:"container"`
+    Clusters  []ClusterRecommendation `json:"clusters"`
+}
+
+type ClusterRecommendation struct {
+    Cluster string          `json:"cluster"`
+    Request v1.ResourceList `json:"request,omitempty"`
+    Limit   v1.ResourceList `json:"limit,omitempty"`
 }
 
 type SlackMessage struct {
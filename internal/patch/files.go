@@ -0,0 +1,68 @@
+package patch
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tabed23/k8s-resource-tuner/internal/models"
+)
+
+// WriteFiles writes, for every entry, a strategic merge patch, a JSON patch
+// and a shell script running the matching kubectl patch command, laid out
+// as <dir>/[<cluster>/]<namespace>/<kind>-<name>.*. An apply.sh at the root
+// runs every command. Workloads whose pod template cannot be patched, such
+// as Jobs, are skipped. targets maps the cluster of an entry to the kubectl
+// target of the commands. It returns the paths of the written files.
+func WriteFiles(dir string, entries []models.ReportEntry, targets map[string]KubeTarget) ([]string, error) {
+	var written []string
+	var commands []string
+	for _, e := range entries {
+		w := e.Workload
+		strategic, err := StrategicMerge(w, e.Recommendation)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Skipping patches for %s %s/%s: %v\n", w.Kind, w.Namespace, w.Name, err)
+			continue
+		}
+		jsonPatch, err := JSONPatch(w, e.Recommendation)
+		if err != nil {
+			return written, err
+		}
+		command := KubectlCommand(targets[e.Cluster], w, strategic)
+		commands = append(commands, command)
+
+		base := filepath.Join(dir, e.Cluster, w.Namespace, fmt.Sprintf("%s-%s", strings.ToLower(w.Kind), w.Name))
+		if err := os.MkdirAll(filepath.Dir(base), 0o755); err != nil {
+			return written, fmt.Errorf("failed to create patch directory: %v", err)
+		}
+		files := []struct {
+			path    string
+			content []byte
+			mode    os.FileMode
+		}{
+			{base + ".strategic.json", append(strategic, '\n'), 0o644},
+			{base + ".jsonpatch.json", append(jsonPatch, '\n'), 0o644},
+			{base + ".sh", []byte(script(command)), 0o755},
+		}
+		for _, f := range files {
+			if err := os.WriteFile(f.path, f.content, f.mode); err != nil {
+				return written, fmt.Errorf("failed to write patch: %v", err)
+			}
+			written = append(written, f.path)
+		}
+	}
+
+	if len(commands) > 0 {
+		all := filepath.Join(dir, "apply.sh")
+		if err := os.WriteFile(all, []byte(script(commands...)), 0o755); err != nil {
+			return written, fmt.Errorf("failed to write patch: %v", err)
+		}
+		written = append(written, all)
+	}
+	return written, nil
+}
+
+func script(commands ...string) string {
+	return "#!/bin/sh\n# Generated by k8s-resource-tuner\nset -e\n\n" + strings.Join(commands, "\n") + "\n"
+}
@@ -0,0 +1,210 @@
+package patch
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/tabed23/k8s-resource-tuner/internal/models"
+	v1 "k8s.io/api/core/v1"
+)
+
+// managed are the resources the tuner recommends. Other entries of a
+// container's requests and limits, such as GPUs, are left untouched.
+var managed = []v1.ResourceName{v1.ResourceCPU, v1.ResourceMemory}
+
+// TemplatePath returns the path of the pod template spec inside the
+// workload object.
+func TemplatePath(kind string) ([]string, error) {
+	switch kind {
+	case models.KindDeployment, models.KindStatefulSet, models.KindDaemonSet:
+		return []string{"spec", "template", "spec"}, nil
+	case models.KindCronJob:
+		return []string{"spec", "jobTemplate", "spec", "template", "spec"}, nil
+	default:
+		return nil, fmt.Errorf("%s pod templates cannot be patched", kind)
+	}
+}
+
+// containerField is the pod spec list holding the container.
+func containerField(c models.ContainerSpec) string {
+	if c.Role == models.RoleInit || c.Role == models.RoleSidecar {
+		return "initContainers"
+	}
+	return "containers"
+}
+
+func recommendationFor(recs []models.Recommendation, container string) (models.Recommendation, bool) {
+	for _, rec := range recs {
+		if rec.ContainerName == container {
+			return rec, true
+		}
+	}
+	return models.Recommendation{}, false
+}
+
+// StrategicMerge builds a strategic merge patch setting the requests and
+// limits of the workload's containers to the recommended values.
+func StrategicMerge(w models.WorkLoad, recs []models.Recommendation) ([]byte, error) {
+	path, err := TemplatePath(w.Kind)
+	if err != nil {
+		return nil, err
+	}
+	podSpec := map[string]interface{}{}
+	for _, c := range w.Containers {
+		rec, ok := recommendationFor(recs, c.Name)
+		if !ok {
+			continue
+		}
+		resources := map[string]map[v1.ResourceName]interface{}{}
+		if values := mergeValues(c.Resources.Request, rec.RecommendedRequest.Request); len(values) > 0 {
+			resources["requests"] = values
+		}
+		if values := mergeValues(c.Resources.Limits, rec.RecommendedLimit.Limits); len(values) > 0 {
+			resources["limits"] = values
+		}
+		field := containerField(c)
+		list, _ := podSpec[field].([]map[string]interface{})
+		podSpec[field] = append(list, map[string]interface{}{"name": c.Name, "resources": resources})
+	}
+	return json.Marshal(nest(path, podSpec))
+}
+
+// mergeValues sets the recommended values and deletes, with a null value,
+// the managed entries the recommendation leaves out, such as a CPU limit
+// under a no-CPU-limit policy.
+func mergeValues(current, recommended v1.ResourceList) map[v1.ResourceName]interface{} {
+	values := map[v1.ResourceName]interface{}{}
+	for name, q := range recommended {
+		values[name] = q.String()
+	}
+	for _, name := range managed {
+		if _, ok := recommended[name]; !ok {
+			if _, set := current[name]; set {
+				values[name] = nil
+			}
+		}
+	}
+	return values
+}
+
+func nest(path []string, leaf interface{}) map[string]interface{} {
+	obj := map[string]interface{}{path[len(path)-1]: leaf}
+	for i := len(path) - 2; i >= 0; i-- {
+		obj = map[string]interface{}{path[i]: obj}
+	}
+	return obj
+}
+
+// Operation is one RFC 6902 JSON patch operation.
+type Operation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// JSONPatch builds an RFC 6902 patch replacing the requests and limits of
+// the workload's containers. Each container is addressed by index and
+// guarded by a test of its name, so the patch fails instead of touching the
+// wrong container when the template changed since the scan.
+func JSONPatch(w models.WorkLoad, recs []models.Recommendation) ([]byte, error) {
+	path, err := TemplatePath(w.Kind)
+	if err != nil {
+		return nil, err
+	}
+	base := "/" + strings.Join(path, "/")
+	ops := []Operation{}
+	indexes := map[string]int{}
+	for _, c := range w.Containers {
+		field := containerField(c)
+		index := indexes[field]
+		indexes[field]++
+		rec, ok := recommendationFor(recs, c.Name)
+		if !ok {
+			continue
+		}
+		prefix := fmt.Sprintf("%s/%s/%d", base, field, index)
+		ops = append(ops, Operation{Op: "test", Path: prefix + "/name", Value: c.Name})
+		ops = append(ops, replaceList(prefix+"/resources/requests", c.Resources.Request, rec.RecommendedRequest.Request)...)
+		ops = append(ops, replaceList(prefix+"/resources/limits", c.Resources.Limits, rec.RecommendedLimit.Limits)...)
+	}
+	return json.Marshal(ops)
+}
+
+// replaceList replaces the managed entries of a resource list while keeping
+// the others, removing the list when nothing remains.
+func replaceList(path string, current, recommended v1.ResourceList) []Operation {
+	values := map[v1.ResourceName]string{}
+	for name, q := range current {
+		if !isManaged(name) {
+			values[name] = q.String()
+		}
+	}
+	for name, q := range recommended {
+		values[name] = q.String()
+	}
+	switch {
+	case len(values) > 0:
+		return []Operation{{Op: "add", Path: path, Value: values}}
+	case len(current) > 0:
+		return []Operation{{Op: "remove", Path: path}}
+	default:
+		return nil
+	}
+}
+
+func isManaged(name v1.ResourceName) bool {
+	for _, m := range managed {
+		if m == name {
+			return true
+		}
+	}
+	return false
+}
+
+// KubeTarget is the kubeconfig and context kubectl reaches a cluster with.
+// Empty fields are left to kubectl's defaults.
+type KubeTarget struct {
+	Kubeconfig string
+	Context    string
+}
+
+// KubectlCommand returns the kubectl invocation applying a strategic merge
+// patch to the workload in the target cluster. Every argument taken from the
+// configuration or the cluster is single-quoted for sh.
+func KubectlCommand(target KubeTarget, w models.WorkLoad, strategic []byte) string {
+	cmd := "kubectl"
+	if target.Kubeconfig != "" {
+		cmd += " --kubeconfig " + shellQuote(target.Kubeconfig)
+	}
+	if target.Context != "" {
+		cmd += " --context " + shellQuote(target.Context)
+	}
+	return fmt.Sprintf("%s -n %s patch %s %s --type=strategic -p %s",
+		cmd, shellQuote(w.Namespace), strings.ToLower(w.Kind), shellQuote(w.Name), shellQuote(string(strategic)))
+}
+
+// shellQuote wraps s in single quotes, closing and reopening them around
+// any single quote inside.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// Annotate adds metadata annotations of the workload object to a strategic
+// merge patch. Annotations with an empty value are deleted.
+func Annotate(strategic []byte, annotations map[string]string) ([]byte, error) {
+	obj := map[string]interface{}{}
+	if err := json.Unmarshal(strategic, &obj); err != nil {
+		return nil, fmt.Errorf("failed to decode patch: %v", err)
+	}
+	values := map[string]interface{}{}
+	for k, v := range annotations {
+		if v == "" {
+			values[k] = nil
+		} else {
+			values[k] = v
+		}
+	}
+	obj["metadata"] = map[string]interface{}{"annotations": values}
+	return json.Marshal(obj)
+}
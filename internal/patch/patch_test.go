@@ -0,0 +1,59 @@
+package patch
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/tabed23/k8s-resource-tuner/internal/models"
+)
+
+func TestKubectlCommand(t *testing.T) {
+	api := models.WorkLoad{Namespace: "shop", Name: "api", Kind: models.KindDeployment}
+	payload := []byte(`{"spec":{}}`)
+	tests := []struct {
+		name   string
+		target KubeTarget
+		want   string
+	}{
+		{"default target", KubeTarget{},
+			`kubectl -n 'shop' patch deployment 'api' --type=strategic -p '{"spec":{}}'`},
+		{"kubeconfig and context", KubeTarget{Kubeconfig: "/home/ops/kube config", Context: "prod"},
+			`kubectl --kubeconfig '/home/ops/kube config' --context 'prod' -n 'shop' patch deployment 'api' --type=strategic -p '{"spec":{}}'`},
+		{"shell metacharacters", KubeTarget{Context: "prod; rm -rf $HOME"},
+			`kubectl --context 'prod; rm -rf $HOME' -n 'shop' patch deployment 'api' --type=strategic -p '{"spec":{}}'`},
+		{"single quote", KubeTarget{Context: "ops's"},
+			`kubectl --context 'ops'\''s' -n 'shop' patch deployment 'api' --type=strategic -p '{"spec":{}}'`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := KubectlCommand(tt.target, api, payload); got != tt.want {
+				t.Errorf("command =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKubectlCommandShellArguments(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh not available")
+	}
+	target := KubeTarget{Kubeconfig: "/tmp/kube config", Context: "it's `prod`; $(exit 1)"}
+	w := models.WorkLoad{Namespace: "shop", Name: "api", Kind: models.KindStatefulSet}
+	payload := `{"metadata":{"annotations":{"note":"it's fine"}}}`
+
+	// Replace kubectl with a function printing one argument per line.
+	script := `kubectl() { printf '%s\n' "$@"; }; ` + KubectlCommand(target, w, []byte(payload))
+	out, err := exec.Command(sh, "-c", script).Output()
+	if err != nil {
+		t.Fatalf("sh: %v", err)
+	}
+	want := []string{
+		"--kubeconfig", target.Kubeconfig, "--context", target.Context,
+		"-n", "shop", "patch", "statefulset", "api", "--type=strategic", "-p", payload,
+	}
+	if got := strings.Split(strings.TrimSuffix(string(out), "\n"), "\n"); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("arguments = %q, want %q", got, want)
+	}
+}
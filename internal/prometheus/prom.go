@@ -4,14 +4,22 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
+
+	"github.com/tabed23/k8s-resource-tuner/internal/models"
 )
 
 type PromClient struct {
 	BaseURL string
 	Client  *http.Client
+	// Optional credentials, either a bearer token or basic auth.
+	BearerToken string
+	Username    string
+	Password    string
 }
 
 type promQueryResult struct {
@@ -32,6 +40,20 @@ func NewPromClient(url string) *PromClient {
 	}
 }
 func (pc *PromClient) QueryRange(query string, start, end time.Time, step string) ([]float64, error) {
+	points, err := pc.QueryRangePoints(query, start, end, step)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]float64, len(points))
+	for i, p := range points {
+		values[i] = p.Value
+	}
+	return values, nil
+}
+
+// QueryRangePoints returns the values of every series of a range query with
+// their timestamps, one series after the other.
+func (pc *PromClient) QueryRangePoints(query string, start, end time.Time, step string) ([]models.Point, error) {
 	u, err := url.Parse(fmt.Sprintf("%s/api/v1/query_range", pc.BaseURL))
 	if err != nil {
 		return nil, err
@@ -44,7 +66,17 @@ func (pc *PromClient) QueryRange(query string, start, end time.Time, step string
 	q.Set("step", step)
 	u.RawQuery = q.Encode()
 
-	resp, err := pc.Client.Get(u.String())
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if pc.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+pc.BearerToken)
+	} else if pc.Username != "" {
+		req.SetBasicAuth(pc.Username, pc.Password)
+	}
+
+	resp, err := pc.Client.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -65,37 +97,51 @@ func (pc *PromClient) QueryRange(query string, start, end time.Time, step string
 		return nil, fmt.Errorf("query failed: %s", result.Status)
 	}
 
-	values := []float64{}
+	points := []models.Point{}
 	for _, res := range result.Data.Result {
 		for _, v := range res.Values {
 			if len(v) < 2 {
 				continue
 			}
+			ts, ok := v[0].(float64)
+			if !ok {
+				continue
+			}
 			valStr, ok := v[1].(string)
 			if !ok {
 				continue
 			}
 			var f float64
 			fmt.Sscanf(valStr, "%f", &f)
-			values = append(values, f)
+			sec, frac := math.Modf(ts)
+			points = append(points, models.Point{Timestamp: time.Unix(int64(sec), int64(frac*1e9)), Value: f})
 		}
 	}
-	return values, nil
+	return points, nil
+}
+
+// podMatcher escapes a pod name regular expression for use inside a
+// double-quoted PromQL label matcher.
+func podMatcher(podPattern string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(podPattern)
 }
 
-func (pc *PromClient) QueryCpu(namespace string, deploy string, start, end time.Time, step string) ([]float64, error) {
-	query := fmt.Sprintf(`sum(rate(container_cpu_usage_seconds_total{namespace="%s", pod=~"%s-.*"}[5m])) by (pod)`, namespace, deploy)
-	return pc.QueryRange(query, start, end, step)
+// QueryCpu returns the CPU usage samples of one container across the matched
+// pods, so sidecars such as istio-proxy are measured separately from the app
+// container.
+func (pc *PromClient) QueryCpu(namespace string, podPattern string, container string, start, end time.Time, step string) ([]models.Point, error) {
+	query := fmt.Sprintf(`sum(rate(container_cpu_usage_seconds_total{namespace="%s", pod=~"%s", container="%s"}[5m])) by (pod)`, namespace, podMatcher(podPattern), container)
+	return pc.QueryRangePoints(query, start, end, step)
 
 }
-func (pc *PromClient) QueryMemory(namespace string, deploy string, start, end time.Time, step string) ([]float64, error) {
-	query := fmt.Sprintf(`max_over_time(container_memory_usage_bytes{namespace="%s", pod=~"%s-.*"}[5m])`, namespace, deploy)
-	return pc.QueryRange(query, start, end, step)
+func (pc *PromClient) QueryMemory(namespace string, podPattern string, container string, start, end time.Time, step string) ([]models.Point, error) {
+	query := fmt.Sprintf(`max_over_time(container_memory_usage_bytes{namespace="%s", pod=~"%s", container="%s"}[5m])`, namespace, podMatcher(podPattern), container)
+	return pc.QueryRangePoints(query, start, end, step)
 }
 
 
-func (pc *PromClient) QueryCurrentCpu(namespace string, deploy string) (float64, error) {
-	query := fmt.Sprintf(`sum(rate(container_cpu_usage_seconds_total{namespace="%s", pod=~"%s-.*"}[1m]))`, namespace, deploy)
+func (pc *PromClient) QueryCurrentCpu(namespace string, podPattern string, container string) (float64, error) {
+	query := fmt.Sprintf(`max(rate(container_cpu_usage_seconds_total{namespace="%s", pod=~"%s", container="%s"}[1m]))`, namespace, podMatcher(podPattern), container)
 	values, err := pc.QueryRange(query, time.Now().Add(-1*time.Minute), time.Now(), "60s")
 	if err != nil || len(values) == 0 {
 		return 0, err
@@ -103,8 +149,8 @@ func (pc *PromClient) QueryCurrentCpu(namespace string, deploy string) (float64,
 	return values[0], nil
 }
 
-func (pc *PromClient) QueryCurrentMemory(namespace string, deploy string) (float64, error) {
-	query := fmt.Sprintf(`max(container_memory_usage_bytes{namespace="%s", pod=~"%s-.*"})`, namespace, deploy)
+func (pc *PromClient) QueryCurrentMemory(namespace string, podPattern string, container string) (float64, error) {
+	query := fmt.Sprintf(`max(container_memory_usage_bytes{namespace="%s", pod=~"%s", container="%s"})`, namespace, podMatcher(podPattern), container)
 	values, err := pc.QueryRange(query, time.Now().Add(-1*time.Minute), time.Now(), "60s")
 	if err != nil || len(values) == 0 {
 		return 0, err
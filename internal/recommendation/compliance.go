@@ -0,0 +1,75 @@
+package recommendation
+
+import (
+	"fmt"
+
+	"github.com/tabed23/k8s-resource-tuner/internal/models"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// CheckCompliance lists the ways the container's current requests and
+// limits break the limit modes and QoS target of the policy.
+func CheckCompliance(c models.ContainerSpec, p Policy) []string {
+	var violations []string
+	for _, r := range []struct {
+		name v1.ResourceName
+		mode LimitMode
+	}{{v1.ResourceCPU, p.cpuLimitMode()}, {v1.ResourceMemory, p.memoryLimitMode()}} {
+		request, hasRequest := c.Resources.Request[r.name]
+		limit, hasLimit := c.Resources.Limits[r.name]
+		switch r.mode {
+		case LimitNone:
+			if hasLimit {
+				violations = append(violations, fmt.Sprintf("%s limit %s is set, policy %s requires no %s limit",
+					r.name, limit.String(), p.Name, r.name))
+			}
+		case LimitEqualRequest:
+			if !hasLimit || !hasRequest || request.Cmp(limit) != 0 {
+				violations = append(violations, fmt.Sprintf("%s limit %s differs from request %s, policy %s requires them to be equal",
+					r.name, quantityOrUnset(limit, hasLimit), quantityOrUnset(request, hasRequest), p.Name))
+			}
+		}
+	}
+
+	if p.QoS != "" {
+		if qos := containerQOS(c.Resources); qos != p.QoS {
+			violations = append(violations, fmt.Sprintf("QoS class is %s, policy %s targets %s", qos, p.Name, p.QoS))
+		}
+	}
+	return violations
+}
+
+// containerQOS classifies a single container the way the kubelet classifies
+// pods: Guaranteed when CPU and memory requests equal their limits,
+// BestEffort without any requests or limits, Burstable otherwise.
+func containerQOS(r models.ResourceConfig) v1.PodQOSClass {
+	if len(r.Request) == 0 && len(r.Limits) == 0 {
+		return v1.PodQOSBestEffort
+	}
+	if isGuaranteed(r.Request, r.Limits) {
+		return v1.PodQOSGuaranteed
+	}
+	return v1.PodQOSBurstable
+}
+
+func isGuaranteed(req, lim v1.ResourceList) bool {
+	for _, name := range []v1.ResourceName{v1.ResourceCPU, v1.ResourceMemory} {
+		l, ok := lim[name]
+		if !ok {
+			return false
+		}
+		// Requests default to limits when only limits are set.
+		if r, ok := req[name]; ok && r.Cmp(l) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func quantityOrUnset(q resource.Quantity, ok bool) string {
+	if !ok {
+		return "unset"
+	}
+	return q.String()
+}
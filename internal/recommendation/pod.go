@@ -0,0 +1,72 @@
+package recommendation
+
+import (
+	"github.com/tabed23/k8s-resource-tuner/internal/models"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// EffectivePodRequest computes the pod-level request the scheduler uses:
+// the larger of the sum of app and sidecar containers and the peak reached
+// while init containers run one by one next to the sidecars started before
+// them. It also returns, per resource, the init container that sets the
+// effective request, if any.
+func EffectivePodRequest(containers []models.ContainerSpec) (v1.ResourceList, map[v1.ResourceName]string) {
+	effective := v1.ResourceList{}
+	dominant := map[v1.ResourceName]string{}
+	for _, name := range []v1.ResourceName{v1.ResourceCPU, v1.ResourceMemory} {
+		running := resource.Quantity{}
+		for _, c := range containers {
+			if c.Role == models.RoleApp || c.Role == "" {
+				addRequest(&running, c, name)
+			}
+		}
+		sidecars := resource.Quantity{}
+		initPeak := resource.Quantity{}
+		initName := ""
+		for _, c := range containers {
+			switch c.Role {
+			case models.RoleSidecar:
+				addRequest(&sidecars, c, name)
+				if sidecars.Cmp(initPeak) > 0 {
+					initPeak, initName = sidecars.DeepCopy(), c.Name
+				}
+			case models.RoleInit:
+				peak := sidecars.DeepCopy()
+				addRequest(&peak, c, name)
+				if peak.Cmp(initPeak) > 0 {
+					initPeak, initName = peak, c.Name
+				}
+			}
+		}
+		running.Add(sidecars)
+		if initPeak.Cmp(running) > 0 {
+			effective[name] = initPeak
+			dominant[name] = initName
+		} else {
+			effective[name] = running
+		}
+	}
+	return effective, dominant
+}
+
+// WithRecommendedRequests returns a copy of containers whose requests are
+// replaced by the matching recommendations.
+func WithRecommendedRequests(containers []models.ContainerSpec, recs []models.Recommendation) []models.ContainerSpec {
+	out := make([]models.ContainerSpec, 0, len(containers))
+	for _, c := range containers {
+		for _, rec := range recs {
+			if rec.ContainerName == c.Name && rec.RecommendedRequest.Request != nil {
+				c.Resources.Request = rec.RecommendedRequest.Request
+			}
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+func addRequest(sum *resource.Quantity, c models.ContainerSpec, name v1.ResourceName) {
+	if q, ok := c.Resources.Request[name]; ok {
+		sum.Add(q)
+	}
+}
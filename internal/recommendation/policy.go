@@ -0,0 +1,281 @@
+package recommendation
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/tabed23/k8s-resource-tuner/internal/models"
+	"github.com/tabed23/k8s-resource-tuner/internal/stats"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// ResourcePolicy turns the usage samples of one resource into a request and
+// a limit: the target percentile plus a safety margin, clamped to Min/Max
+// and rounded up to a multiple of Round. Zero Min, Max and Round quantities
+// are ignored.
+type ResourcePolicy struct {
+	RequestPercentile float64
+	LimitPercentile   float64
+	MarginPercent     float64
+	Min               resource.Quantity
+	Max               resource.Quantity
+	Round             resource.Quantity
+}
+
+// LimitMode decides how the limit of a resource is derived.
+type LimitMode string
+
+const (
+	// LimitFromPercentile sets the limit from the limit percentile (default).
+	LimitFromPercentile LimitMode = "percentile"
+	// LimitNone omits the limit, e.g. no CPU limits to avoid CFS throttling.
+	LimitNone LimitMode = "none"
+	// LimitEqualRequest pins the limit to the request.
+	LimitEqualRequest LimitMode = "request"
+)
+
+// Policy decides how observed usage becomes requests and limits.
+type Policy struct {
+	Name   string
+	CPU    ResourcePolicy
+	Memory ResourcePolicy
+	// CPULimit and MemoryLimit select the limit mode of each resource,
+	// LimitFromPercentile when empty.
+	CPULimit    LimitMode
+	MemoryLimit LimitMode
+	// QoS, when set to Guaranteed or Burstable, makes the recommended
+	// requests and limits land the container in that QoS class.
+	QoS v1.PodQOSClass
+}
+
+var (
+	Conservative = Policy{
+		Name: "conservative",
+		CPU: ResourcePolicy{RequestPercentile: 99, LimitPercentile: 100, MarginPercent: 25,
+			Min: resource.MustParse("10m"), Round: resource.MustParse("10m")},
+		Memory: ResourcePolicy{RequestPercentile: 99, LimitPercentile: 100, MarginPercent: 25,
+			Min: resource.MustParse("32Mi"), Round: resource.MustParse("16Mi")},
+	}
+	Balanced = Policy{
+		Name: "balanced",
+		CPU: ResourcePolicy{RequestPercentile: 95, LimitPercentile: 99, MarginPercent: 15,
+			Min: resource.MustParse("5m"), Round: resource.MustParse("5m")},
+		Memory: ResourcePolicy{RequestPercentile: 95, LimitPercentile: 99, MarginPercent: 15,
+			Min: resource.MustParse("16Mi"), Round: resource.MustParse("8Mi")},
+	}
+	Aggressive = Policy{
+		Name: "aggressive",
+		CPU: ResourcePolicy{RequestPercentile: 90, LimitPercentile: 95, MarginPercent: 5,
+			Min: resource.MustParse("1m"), Round: resource.MustParse("1m")},
+		Memory: ResourcePolicy{RequestPercentile: 90, LimitPercentile: 95, MarginPercent: 5,
+			Min: resource.MustParse("8Mi"), Round: resource.MustParse("4Mi")},
+	}
+)
+
+// DefaultPolicy is used when nothing selects a policy.
+var DefaultPolicy = Balanced
+
+// Presets returns the built-in policies by name.
+func Presets() map[string]Policy {
+	return map[string]Policy{
+		Conservative.Name: Conservative,
+		Balanced.Name:     Balanced,
+		Aggressive.Name:   Aggressive,
+	}
+}
+
+// PolicyByName returns the built-in policy with the given name.
+func PolicyByName(name string) (Policy, error) {
+	p, ok := Presets()[name]
+	if !ok {
+		return Policy{}, fmt.Errorf("unknown policy %q", name)
+	}
+	return p, nil
+}
+
+// Validate checks that percentiles, margin and bounds are usable.
+func (p Policy) Validate() error {
+	for _, r := range []struct {
+		name string
+		rp   ResourcePolicy
+	}{{"cpu", p.CPU}, {"memory", p.Memory}} {
+		if r.rp.RequestPercentile <= 0 || r.rp.RequestPercentile > 100 ||
+			r.rp.LimitPercentile <= 0 || r.rp.LimitPercentile > 100 {
+			return fmt.Errorf("policy %s: %s percentiles must be in (0, 100]", p.Name, r.name)
+		}
+		if r.rp.LimitPercentile < r.rp.RequestPercentile {
+			return fmt.Errorf("policy %s: %s limit percentile is below the request percentile", p.Name, r.name)
+		}
+		if r.rp.MarginPercent < 0 {
+			return fmt.Errorf("policy %s: %s margin must not be negative", p.Name, r.name)
+		}
+		if r.rp.Min.Sign() < 0 || r.rp.Max.Sign() < 0 || r.rp.Round.Sign() < 0 {
+			return fmt.Errorf("policy %s: %s bounds and rounding must not be negative", p.Name, r.name)
+		}
+		if !r.rp.Max.IsZero() && r.rp.Max.Cmp(r.rp.Min) < 0 {
+			return fmt.Errorf("policy %s: %s max is below min", p.Name, r.name)
+		}
+	}
+	for _, m := range []LimitMode{p.CPULimit, p.MemoryLimit} {
+		switch m {
+		case "", LimitFromPercentile, LimitNone, LimitEqualRequest:
+		default:
+			return fmt.Errorf("policy %s: unknown limit mode %q, use percentile, none or request", p.Name, m)
+		}
+	}
+	switch p.QoS {
+	case "", v1.PodQOSBurstable:
+	case v1.PodQOSGuaranteed:
+		if p.CPULimit == LimitNone || p.MemoryLimit == LimitNone {
+			return fmt.Errorf("policy %s: Guaranteed QoS needs CPU and memory limits", p.Name)
+		}
+	default:
+		return fmt.Errorf("policy %s: unsupported QoS class %q, use Guaranteed or Burstable", p.Name, p.QoS)
+	}
+	return nil
+}
+
+// Describe summarizes the policy for recommendation reasons.
+func (p Policy) Describe() string {
+	s := fmt.Sprintf("policy %s: CPU %s, memory %s", p.Name,
+		p.CPU.describe(p.cpuLimitMode()), p.Memory.describe(p.memoryLimitMode()))
+	if p.QoS != "" {
+		s += fmt.Sprintf(", %s QoS", p.QoS)
+	}
+	return s
+}
+
+func (rp ResourcePolicy) describe(mode LimitMode) string {
+	s := fmt.Sprintf("p%g request", rp.RequestPercentile)
+	switch mode {
+	case LimitNone:
+		s += " / no limit"
+	case LimitEqualRequest:
+		s += " / limit = request"
+	default:
+		s += fmt.Sprintf(" / p%g limit", rp.LimitPercentile)
+	}
+	if rp.MarginPercent > 0 {
+		s += fmt.Sprintf(" +%g%%", rp.MarginPercent)
+	}
+	return s
+}
+
+// cpuLimitMode and memoryLimitMode resolve the effective limit modes, a
+// Guaranteed QoS target pins both limits to the requests.
+func (p Policy) cpuLimitMode() LimitMode {
+	if p.QoS == v1.PodQOSGuaranteed {
+		return LimitEqualRequest
+	}
+	if p.CPULimit == "" {
+		return LimitFromPercentile
+	}
+	return p.CPULimit
+}
+
+func (p Policy) memoryLimitMode() LimitMode {
+	if p.QoS == v1.PodQOSGuaranteed {
+		return LimitEqualRequest
+	}
+	if p.MemoryLimit == "" {
+		return LimitFromPercentile
+	}
+	return p.MemoryLimit
+}
+
+// target applies percentile, margin, bounds and rounding to samples given in
+// base units (cores or bytes) and returns the result in the same unit.
+func (rp ResourcePolicy) target(samples []float64, percentile float64, unit float64) float64 {
+	v := stats.Percentile(samples, percentile) * (1 + rp.MarginPercent/100)
+	if min := rp.Min.AsApproximateFloat64(); v < min {
+		v = min
+	}
+	if max := rp.Max.AsApproximateFloat64(); max > 0 && v > max {
+		v = max
+	}
+	step := rp.Round.AsApproximateFloat64()
+	if step < unit {
+		step = unit
+	}
+	return math.Ceil(v/step-1e-9) * step
+}
+
+// PolicySet selects the policy of each workload. Precedence is the
+// resource-tuner/policy annotation, then Workloads ("namespace/name"), then
+// Namespaces, then Default.
+type PolicySet struct {
+	Policies   map[string]Policy
+	Default    string
+	Namespaces map[string]string
+	Workloads  map[string]string
+}
+
+// DefaultPolicySet knows the presets and defaults to DefaultPolicy.
+func DefaultPolicySet() PolicySet {
+	return PolicySet{Policies: Presets(), Default: DefaultPolicy.Name}
+}
+
+// Lookup returns the policy with the given name.
+func (s PolicySet) Lookup(name string) (Policy, error) {
+	p, ok := s.Policies[name]
+	if !ok {
+		known := make([]string, 0, len(s.Policies))
+		for n := range s.Policies {
+			known = append(known, n)
+		}
+		sort.Strings(known)
+		return Policy{}, fmt.Errorf("unknown policy %q (known: %s)", name, strings.Join(known, ", "))
+	}
+	return p, nil
+}
+
+// For returns the policy of the workload. An unknown name in the workload's
+// annotation is reported and the configured policy is used instead.
+func (s PolicySet) For(w models.WorkLoad) (Policy, error) {
+	name := s.Default
+	if n, ok := s.Namespaces[w.Namespace]; ok {
+		name = n
+	}
+	if n, ok := s.Workloads[w.Namespace+"/"+w.Name]; ok {
+		name = n
+	}
+	configured, err := s.Lookup(name)
+	if err != nil {
+		return DefaultPolicy, err
+	}
+	annotated := w.Annotations[models.AnnotationPolicy]
+	if annotated == "" {
+		return configured, nil
+	}
+	p, err := s.Lookup(annotated)
+	if err != nil {
+		return configured, fmt.Errorf("%s %s/%s: %v", w.Kind, w.Namespace, w.Name, err)
+	}
+	return p, nil
+}
+
+// Validate checks every policy and that every referenced name exists.
+func (s PolicySet) Validate() error {
+	for _, p := range s.Policies {
+		if err := p.Validate(); err != nil {
+			return err
+		}
+	}
+	if _, err := s.Lookup(s.Default); err != nil {
+		return fmt.Errorf("default: %v", err)
+	}
+	for ns, name := range s.Namespaces {
+		if _, err := s.Lookup(name); err != nil {
+			return fmt.Errorf("namespace %s: %v", ns, err)
+		}
+	}
+	for w, name := range s.Workloads {
+		if _, err := s.Lookup(name); err != nil {
+			return fmt.Errorf("workload %s: %v", w, err)
+		}
+	}
+	return nil
+}
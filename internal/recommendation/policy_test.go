@@ -0,0 +1,162 @@
+package recommendation
+
+import (
+	"math"
+	"testing"
+
+	"github.com/tabed23/k8s-resource-tuner/internal/models"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+const mib = 1024 * 1024
+
+// series returns n samples going from step to n*step.
+func series(n int, step float64) []float64 {
+	samples := make([]float64, n)
+	for i := range samples {
+		samples[i] = float64(i+1) * step
+	}
+	return samples
+}
+
+func TestResourcePolicyTarget(t *testing.T) {
+	tests := []struct {
+		name       string
+		policy     ResourcePolicy
+		samples    []float64
+		percentile float64
+		unit       float64
+		want       float64
+	}{
+		{"balanced cpu request", Balanced.CPU, series(100, 0.01), 95, 0.001, 1.095},
+		{"balanced cpu limit", Balanced.CPU, series(100, 0.01), 99, 0.001, 1.14},
+		{"raised to min", Balanced.CPU, []float64{0.0001}, 95, 0.001, 0.005},
+		{"no samples", Balanced.CPU, nil, 95, 0.001, 0.005},
+		{"capped at max", ResourcePolicy{MarginPercent: 10, Max: resource.MustParse("500m")}, []float64{2}, 100, 0.001, 0.5},
+		{"rounded to the unit", ResourcePolicy{}, []float64{0.0123}, 100, 0.001, 0.013},
+		{"multiple of round kept", ResourcePolicy{Round: resource.MustParse("5m")}, []float64{0.5}, 100, 0.001, 0.5},
+		{"memory rounded up", ResourcePolicy{Round: resource.MustParse("8Mi")}, []float64{100 * mib}, 100, 1, 104 * mib},
+		{"memory margin", ResourcePolicy{MarginPercent: 25, Round: resource.MustParse("16Mi")}, series(10, 100*mib), 90, 1, 1136 * mib},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.policy.target(tt.samples, tt.percentile, tt.unit)
+			if math.Abs(got-tt.want) > 1e-9*math.Max(1, tt.want) {
+				t.Errorf("target = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecommendWithPolicyLimits(t *testing.T) {
+	usage := models.UsageStats{ContainerName: "app", CPUSamples: series(100, 0.01), MemSamples: series(100, mib)}
+	noCPULimit := Balanced
+	noCPULimit.CPULimit = LimitNone
+	guaranteed := Balanced
+	guaranteed.QoS = v1.PodQOSGuaranteed
+	burstable := Balanced
+	burstable.CPULimit, burstable.MemoryLimit, burstable.QoS = LimitEqualRequest, LimitEqualRequest, v1.PodQOSBurstable
+
+	tests := []struct {
+		name    string
+		policy  Policy
+		wantReq map[v1.ResourceName]string
+		wantLim map[v1.ResourceName]string
+	}{
+		{"percentile limits", Balanced,
+			map[v1.ResourceName]string{v1.ResourceCPU: "1095m", v1.ResourceMemory: "112Mi"},
+			map[v1.ResourceName]string{v1.ResourceCPU: "1140m", v1.ResourceMemory: "120Mi"}},
+		{"no cpu limit", noCPULimit,
+			map[v1.ResourceName]string{v1.ResourceCPU: "1095m", v1.ResourceMemory: "112Mi"},
+			map[v1.ResourceName]string{v1.ResourceMemory: "120Mi"}},
+		{"guaranteed", guaranteed,
+			map[v1.ResourceName]string{v1.ResourceCPU: "1095m", v1.ResourceMemory: "112Mi"},
+			map[v1.ResourceName]string{v1.ResourceCPU: "1095m", v1.ResourceMemory: "112Mi"}},
+		{"burstable drops the cpu limit", burstable,
+			map[v1.ResourceName]string{v1.ResourceCPU: "1095m", v1.ResourceMemory: "112Mi"},
+			map[v1.ResourceName]string{v1.ResourceMemory: "112Mi"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := RecommendWithPolicy(usage, tt.policy)
+			assertResources(t, "request", rec.RecommendedRequest.Request, tt.wantReq)
+			assertResources(t, "limit", rec.RecommendedLimit.Limits, tt.wantLim)
+		})
+	}
+}
+
+func assertResources(t *testing.T, field string, got v1.ResourceList, want map[v1.ResourceName]string) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Errorf("%s = %v, want %v", field, got, want)
+		return
+	}
+	for name, w := range want {
+		if q, ok := got[name]; !ok || q.Cmp(resource.MustParse(w)) != 0 {
+			t.Errorf("%s %s = %s, want %s", field, name, q.String(), w)
+		}
+	}
+}
+
+func TestPolicySetFor(t *testing.T) {
+	set := PolicySet{
+		Policies:   Presets(),
+		Default:    Balanced.Name,
+		Namespaces: map[string]string{"jobs": Aggressive.Name, "legacy": "missing"},
+		Workloads:  map[string]string{"jobs/report": Conservative.Name},
+	}
+	workload := func(namespace, name, annotation string) models.WorkLoad {
+		w := models.WorkLoad{Namespace: namespace, Name: name, Kind: models.KindDeployment}
+		if annotation != "" {
+			w.Annotations = map[string]string{models.AnnotationPolicy: annotation}
+		}
+		return w
+	}
+	tests := []struct {
+		name     string
+		workload models.WorkLoad
+		want     string
+		wantErr  bool
+	}{
+		{"default", workload("shop", "api", ""), Balanced.Name, false},
+		{"namespace", workload("jobs", "cleanup", ""), Aggressive.Name, false},
+		{"workload over namespace", workload("jobs", "report", ""), Conservative.Name, false},
+		{"workload name is namespaced", workload("shop", "report", ""), Balanced.Name, false},
+		{"annotation over workload", workload("jobs", "report", Aggressive.Name), Aggressive.Name, false},
+		{"annotation over default", workload("shop", "api", Conservative.Name), Conservative.Name, false},
+		{"unknown annotation keeps the configured policy", workload("jobs", "report", "missing"), Conservative.Name, true},
+		{"unknown configured policy falls back to the default", workload("legacy", "api", ""), DefaultPolicy.Name, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := set.For(tt.workload)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if p.Name != tt.want {
+				t.Errorf("policy = %s, want %s", p.Name, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicySetValidate(t *testing.T) {
+	valid := DefaultPolicySet()
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("default policy set: %v", err)
+	}
+	invalid := Balanced
+	invalid.Name = "invalid"
+	invalid.CPU.LimitPercentile = 50
+	for name, set := range map[string]PolicySet{
+		"unknown default":   {Policies: Presets(), Default: "missing"},
+		"unknown namespace": {Policies: Presets(), Default: Balanced.Name, Namespaces: map[string]string{"shop": "missing"}},
+		"unknown workload":  {Policies: Presets(), Default: Balanced.Name, Workloads: map[string]string{"shop/api": "missing"}},
+		"invalid policy":    {Policies: map[string]Policy{"invalid": invalid}, Default: "invalid"},
+	} {
+		if err := set.Validate(); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
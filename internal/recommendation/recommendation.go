@@ -9,49 +9,74 @@ import (
 	"k8s.io/apimachinery/pkg/api/resource"
 )
 
-func roundMillicores(cpu float64) string {
-	mc := math.Ceil(cpu * 1000)
-	return fmt.Sprintf("%.0fm", mc)
-}
-
+// MillicoresToCores converts a CPU quantity string such as "250m" or "2"
+// to cores.
 func MillicoresToCores(millicores string) float64 {
-    var m float64
-    fmt.Sscanf(millicores, "%fm", &m)
-    return m / 1000.0
-}
-
-func roundMiB(memBytes float64) string {
-	mb := math.Ceil(memBytes / 1024 / 1024)
-	return fmt.Sprintf("%.0fMi", mb)
-}
-
-func resourceMustParse(val string) resource.Quantity {
-	q, err := resource.ParseQuantity(val)
+	q, err := resource.ParseQuantity(millicores)
 	if err != nil {
-		return resource.MustParse("1")
+		return 0
 	}
-	return q
+	return q.AsApproximateFloat64()
 }
 
 func RecommendFromStats(stats models.UsageStats) models.Recommendation {
-	cpuRequest := stats.CPUP95
-	cpuLimit := stats.CPUP99
+	return RecommendWithPolicy(stats, DefaultPolicy)
+}
+
+// RecommendWithPolicy derives requests and limits from the usage samples
+// as described by the policy, and records the policy in the reason.
+func RecommendWithPolicy(usage models.UsageStats, policy Policy) models.Recommendation {
+	cpuRequest := policy.CPU.target(usage.CPUSamples, policy.CPU.RequestPercentile, 0.001)
+	cpuLimit := math.Max(cpuRequest, policy.CPU.target(usage.CPUSamples, policy.CPU.LimitPercentile, 0.001))
 
-	memRequest := stats.MemP95
-	memLimit := stats.MemP99
+	memRequest := policy.Memory.target(usage.MemSamples, policy.Memory.RequestPercentile, 1)
+	memLimit := math.Max(memRequest, policy.Memory.target(usage.MemSamples, policy.Memory.LimitPercentile, 1))
 
 	req := v1.ResourceList{
-		v1.ResourceCPU:    resourceMustParse(roundMillicores(cpuRequest)),
-		v1.ResourceMemory: resourceMustParse(roundMiB(memRequest)),
+		v1.ResourceCPU:    cpuQuantity(cpuRequest),
+		v1.ResourceMemory: memoryQuantity(memRequest),
 	}
-	lim := v1.ResourceList{
-		v1.ResourceCPU:    resourceMustParse(roundMillicores(cpuLimit)),
-		v1.ResourceMemory: resourceMustParse(roundMiB(memLimit)),
+	lim := v1.ResourceList{}
+	setLimit(lim, v1.ResourceCPU, policy.cpuLimitMode(), cpuQuantity(cpuRequest), cpuQuantity(cpuLimit))
+	setLimit(lim, v1.ResourceMemory, policy.memoryLimitMode(), memoryQuantity(memRequest), memoryQuantity(memLimit))
+	if policy.QoS == v1.PodQOSBurstable && isGuaranteed(req, lim) {
+		// Equal requests and limits would make the container Guaranteed,
+		// dropping the CPU limit keeps it Burstable.
+		delete(lim, v1.ResourceCPU)
 	}
 	return models.Recommendation{
-		ContainerName:      stats.ContainerName,
+		ContainerName:      usage.ContainerName,
 		RecommendedRequest: models.ResourceConfig{Request: req},
 		RecommendedLimit:   models.ResourceConfig{Limits: lim},
-		Reason:             "Based on observed p95 (requests) and p99 (limits) from recent metrics",
+		Reason:             fmt.Sprintf("Based on observed usage from recent metrics (%s)", policy.Describe()),
+	}
+}
+
+func setLimit(lim v1.ResourceList, name v1.ResourceName, mode LimitMode, request, limit resource.Quantity) {
+	switch mode {
+	case LimitNone:
+	case LimitEqualRequest:
+		lim[name] = request
+	default:
+		lim[name] = limit
+	}
+}
+
+func cpuQuantity(cores float64) resource.Quantity {
+	return *resource.NewMilliQuantity(int64(math.Round(cores*1000)), resource.DecimalSI)
+}
+
+func memoryQuantity(bytes float64) resource.Quantity {
+	return *resource.NewQuantity(int64(math.Round(bytes)), resource.BinarySI)
+}
+
+// KeepCurrent recommends the container's current resources unchanged, used
+// when there is no usage to base a recommendation on.
+func KeepCurrent(container models.ContainerSpec, reason string) models.Recommendation {
+	return models.Recommendation{
+		ContainerName:      container.Name,
+		RecommendedRequest: models.ResourceConfig{Request: container.Resources.Request},
+		RecommendedLimit:   models.ResourceConfig{Limits: container.Resources.Limits},
+		Reason:             reason,
 	}
 }
@@ -0,0 +1,78 @@
+package report
+
+import (
+	"sort"
+
+	"github.com/tabed23/k8s-resource-tuner/internal/models"
+)
+
+// MergeReports combines per-cluster reports into one, tagging the clusters
+// it covers and comparing workloads that run in more than one of them.
+func MergeReports(reports []models.Report) models.Report {
+	merged := models.Report{}
+	seen := map[string]bool{}
+	for _, r := range reports {
+		merged.Entries = append(merged.Entries, r.Entries...)
+		if merged.Lookback == "" {
+			merged.Lookback = r.Lookback
+		}
+		for _, e := range r.Entries {
+			if e.Cluster != "" && !seen[e.Cluster] {
+				seen[e.Cluster] = true
+				merged.Clusters = append(merged.Clusters, e.Cluster)
+			}
+		}
+		if r.Timestamp.After(merged.Timestamp) {
+			merged.Timestamp = r.Timestamp
+		}
+	}
+	sort.Strings(merged.Clusters)
+	sort.SliceStable(merged.Entries, func(i, j int) bool {
+		return merged.Entries[i].Cluster < merged.Entries[j].Cluster
+	})
+	merged.Comparisons = CompareClusters(merged.Entries)
+	merged.Summary = Summarize(merged.Entries)
+	return merged
+}
+
+// CompareClusters groups container recommendations by namespace, kind,
+// workload and container name, keeping only those seen in several clusters.
+func CompareClusters(entries []models.ReportEntry) []models.ClusterComparison {
+	type workloadContainer struct {
+		namespace, kind, name, container string
+	}
+	index := map[workloadContainer]int{}
+	var comparisons []models.ClusterComparison
+	for _, e := range entries {
+		if e.Cluster == "" {
+			continue
+		}
+		for _, rec := range e.Recommendation {
+			key := workloadContainer{e.Workload.Namespace, e.Workload.Kind, e.Workload.Name, rec.ContainerName}
+			i, ok := index[key]
+			if !ok {
+				i = len(comparisons)
+				index[key] = i
+				comparisons = append(comparisons, models.ClusterComparison{
+					Namespace: key.namespace,
+					Kind:      key.kind,
+					Name:      key.name,
+					Container: key.container,
+				})
+			}
+			comparisons[i].Clusters = append(comparisons[i].Clusters, models.ClusterRecommendation{
+				Cluster: e.Cluster,
+				Request: rec.RecommendedRequest.Request,
+				Limit:   rec.RecommendedLimit.Limits,
+			})
+		}
+	}
+
+	var multi []models.ClusterComparison
+	for _, c := range comparisons {
+		if len(c.Clusters) > 1 {
+			multi = append(multi, c)
+		}
+	}
+	return multi
+}
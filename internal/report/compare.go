@@ -0,0 +1,295 @@
+package report
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/tabed23/k8s-resource-tuner/internal/models"
+	v1 "k8s.io/api/core/v1"
+)
+
+// RunComparison tells what changed between two runs: the workloads that
+// appeared or disappeared, the recommendations that moved, the workloads
+// whose resources were changed in between, and the monthly cost.
+type RunComparison struct {
+	From             RunRef                  `json:"from"`
+	To               RunRef                  `json:"to"`
+	ThresholdPercent float64                 `json:"threshold_percent"`
+	Appeared         []models.WorkloadTotals `json:"appeared"`
+	Disappeared      []models.WorkloadTotals `json:"disappeared"`
+	Changed          []RecommendationChange  `json:"changed"`
+	Applied          []AppliedChange         `json:"applied"`
+	// Cost is set when both runs are priced in the same currency.
+	Cost *CostDelta `json:"cost,omitempty"`
+}
+
+// RunRef identifies one side of a comparison.
+type RunRef struct {
+	RunID     string    `json:"run_id,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+	Lookback  string    `json:"lookback,omitempty"`
+}
+
+// RecommendationChange is a recommended value of a container that moved
+// between the runs.
+type RecommendationChange struct {
+	Cluster      string          `json:"cluster,omitempty"`
+	Namespace    string          `json:"namespace"`
+	Kind         string          `json:"kind"`
+	Workload     string          `json:"workload"`
+	Container    string          `json:"container"`
+	Resource     v1.ResourceName `json:"resource"`
+	Field        string          `json:"field"` // "request" or "limit"
+	From         string          `json:"from,omitempty"`
+	To           string          `json:"to,omitempty"`
+	DeltaPercent float64         `json:"delta_percent"`
+}
+
+// AppliedChange is a workload whose current requests or limits changed
+// between the runs, by apply, gitops or by hand. Waste is the requested CPU
+// (cores) and memory (GiB) above the recommendation of each run, over all
+// replicas.
+type AppliedChange struct {
+	Cluster           string        `json:"cluster,omitempty"`
+	Namespace         string        `json:"namespace"`
+	Kind              string        `json:"kind"`
+	Name              string        `json:"name"`
+	Before            models.Totals `json:"before"`
+	After             models.Totals `json:"after"`
+	CPUWasteBefore    float64       `json:"cpu_waste_before_cores"`
+	CPUWasteAfter     float64       `json:"cpu_waste_after_cores"`
+	MemoryWasteBefore float64       `json:"memory_waste_before_gib"`
+	MemoryWasteAfter  float64       `json:"memory_waste_after_gib"`
+	// ReducedWaste is set when neither waste grew and one of them shrank.
+	ReducedWaste bool `json:"reduced_waste"`
+}
+
+// CostDelta compares the monthly cost of the two runs.
+type CostDelta struct {
+	Currency        string  `json:"currency"`
+	FromCurrent     float64 `json:"from_current"`
+	ToCurrent       float64 `json:"to_current"`
+	Delta           float64 `json:"delta"`
+	FromRecommended float64 `json:"from_recommended"`
+	ToRecommended   float64 `json:"to_recommended"`
+	FromSavings     float64 `json:"from_savings"`
+	ToSavings       float64 `json:"to_savings"`
+}
+
+// CompareRuns compares the report of an earlier run with a later one.
+// Recommended values that are added, removed or move by more than
+// thresholdPercent count as changed.
+func CompareRuns(from, to models.Report, thresholdPercent float64) RunComparison {
+	c := RunComparison{
+		From:             RunRef{RunID: from.RunID, Timestamp: from.Timestamp, Lookback: from.Lookback},
+		To:               RunRef{RunID: to.RunID, Timestamp: to.Timestamp, Lookback: to.Lookback},
+		ThresholdPercent: thresholdPercent,
+		Appeared:         []models.WorkloadTotals{},
+		Disappeared:      []models.WorkloadTotals{},
+		Changed:          []RecommendationChange{},
+		Applied:          []AppliedChange{},
+	}
+	before, after := entriesByKey(from.Entries), entriesByKey(to.Entries)
+
+	for _, key := range sortedKeys(before) {
+		if _, ok := after[key]; !ok {
+			c.Disappeared = append(c.Disappeared, workloadTotals(before[key]))
+		}
+	}
+	for _, key := range sortedKeys(after) {
+		a := after[key]
+		b, ok := before[key]
+		if !ok {
+			c.Appeared = append(c.Appeared, workloadTotals(a))
+			continue
+		}
+		c.Changed = append(c.Changed, recommendationChanges(b, a, thresholdPercent)...)
+		if resourcesChanged(b, a) {
+			c.Applied = append(c.Applied, appliedChange(b, a))
+		}
+	}
+
+	fromSummary, toSummary := Summarize(from.Entries), Summarize(to.Entries)
+	if fromSummary.Currency != "" && fromSummary.Currency == toSummary.Currency {
+		f, t := fromSummary.Total, toSummary.Total
+		c.Cost = &CostDelta{
+			Currency:        toSummary.Currency,
+			FromCurrent:     f.CurrentCost,
+			ToCurrent:       t.CurrentCost,
+			Delta:           math.Round((t.CurrentCost-f.CurrentCost)*100) / 100,
+			FromRecommended: f.RecommendedCost,
+			ToRecommended:   t.RecommendedCost,
+			FromSavings:     f.Savings,
+			ToSavings:       t.Savings,
+		}
+	}
+	return c
+}
+
+func entryKey(e models.ReportEntry) string {
+	return e.Cluster + "/" + e.Workload.Namespace + "/" + e.Workload.Kind + "/" + e.Workload.Name
+}
+
+func entriesByKey(entries []models.ReportEntry) map[string]models.ReportEntry {
+	byKey := make(map[string]models.ReportEntry, len(entries))
+	for _, e := range entries {
+		byKey[entryKey(e)] = e
+	}
+	return byKey
+}
+
+func sortedKeys(m map[string]models.ReportEntry) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func workloadTotals(e models.ReportEntry) models.WorkloadTotals {
+	return models.WorkloadTotals{
+		Cluster:   e.Cluster,
+		Namespace: e.Workload.Namespace,
+		Kind:      e.Workload.Kind,
+		Name:      e.Workload.Name,
+		Totals:    Summarize([]models.ReportEntry{e}).Total,
+	}
+}
+
+// recommendationChanges compares the recommended requests and limits of
+// the containers the workload has in both runs.
+func recommendationChanges(before, after models.ReportEntry, thresholdPercent float64) []RecommendationChange {
+	var changes []RecommendationChange
+	for _, rec := range after.Recommendation {
+		var old *models.Recommendation
+		for i := range before.Recommendation {
+			if before.Recommendation[i].ContainerName == rec.ContainerName {
+				old = &before.Recommendation[i]
+			}
+		}
+		if old == nil {
+			continue
+		}
+		for _, name := range []v1.ResourceName{v1.ResourceCPU, v1.ResourceMemory} {
+			for _, ch := range []Change{
+				newChange(after, rec.ContainerName, name, "request", old.RecommendedRequest.Request, rec.RecommendedRequest.Request),
+				newChange(after, rec.ContainerName, name, "limit", old.RecommendedLimit.Limits, rec.RecommendedLimit.Limits),
+			} {
+				if !ch.Exceeds(thresholdPercent) {
+					continue
+				}
+				changes = append(changes, RecommendationChange{
+					Cluster:      ch.Cluster,
+					Namespace:    ch.Namespace,
+					Kind:         ch.Kind,
+					Workload:     ch.Workload,
+					Container:    ch.Container,
+					Resource:     ch.Resource,
+					Field:        ch.Field,
+					From:         ch.Current,
+					To:           ch.Recommended,
+					DeltaPercent: math.Round(ch.DeltaPercent*10) / 10,
+				})
+			}
+		}
+	}
+	return changes
+}
+
+// resourcesChanged reports whether a CPU or memory request or limit of a
+// container differs between the runs.
+func resourcesChanged(before, after models.ReportEntry) bool {
+	for _, c := range after.Workload.Containers {
+		old := containerResources(before.Workload, c.Name)
+		for _, name := range []v1.ResourceName{v1.ResourceCPU, v1.ResourceMemory} {
+			if quantityString(old.Request, name) != quantityString(c.Resources.Request, name) ||
+				quantityString(old.Limits, name) != quantityString(c.Resources.Limits, name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func appliedChange(before, after models.ReportEntry) AppliedChange {
+	a := AppliedChange{
+		Cluster:   after.Cluster,
+		Namespace: after.Workload.Namespace,
+		Kind:      after.Workload.Kind,
+		Name:      after.Workload.Name,
+		Before:    Summarize([]models.ReportEntry{before}).Total,
+		After:     Summarize([]models.ReportEntry{after}).Total,
+	}
+	a.CPUWasteBefore, a.MemoryWasteBefore = waste(before)
+	a.CPUWasteAfter, a.MemoryWasteAfter = waste(after)
+	a.ReducedWaste = a.CPUWasteAfter <= a.CPUWasteBefore && a.MemoryWasteAfter <= a.MemoryWasteBefore &&
+		(a.CPUWasteAfter < a.CPUWasteBefore || a.MemoryWasteAfter < a.MemoryWasteBefore)
+	return a
+}
+
+// waste sums the CPU cores and memory GiB requested above the
+// recommendation by the app and sidecar containers of the entry, over all
+// replicas.
+func waste(e models.ReportEntry) (cpu, memory float64) {
+	n := e.ReplicaCount()
+	for _, rec := range e.Recommendation {
+		if containerRole(e.Workload, rec.ContainerName) == models.RoleInit {
+			continue
+		}
+		current := containerResources(e.Workload, rec.ContainerName)
+		for _, r := range []struct {
+			name  v1.ResourceName
+			scale float64
+			sum   *float64
+		}{{v1.ResourceCPU, 1, &cpu}, {v1.ResourceMemory, gibibyte, &memory}} {
+			var cur, recommended float64
+			if q, ok := current.Request[r.name]; ok {
+				cur = q.AsApproximateFloat64()
+			}
+			if q, ok := rec.RecommendedRequest.Request[r.name]; ok {
+				recommended = q.AsApproximateFloat64()
+			}
+			*r.sum += math.Max(0, cur-recommended) / r.scale * n
+		}
+	}
+	return math.Round(cpu*1000) / 1000, math.Round(memory*1000) / 1000
+}
+
+// runLabel names a side of the comparison by run ID and time.
+func runLabel(r RunRef) string {
+	t := r.Timestamp.Format("2006-01-02 15:04:05")
+	if r.RunID == "" {
+		return t
+	}
+	return fmt.Sprintf("%s (%s)", r.RunID, t)
+}
+
+func changeName(ch RecommendationChange) string {
+	return workloadName(models.WorkloadTotals{Cluster: ch.Cluster, Namespace: ch.Namespace, Kind: ch.Kind, Name: ch.Workload})
+}
+
+func appliedName(a AppliedChange) string {
+	return workloadName(models.WorkloadTotals{Cluster: a.Cluster, Namespace: a.Namespace, Kind: a.Kind, Name: a.Name})
+}
+
+// formatChangeDelta renders the move of a recommended value, "added" or
+// "removed" when it is only set in one run.
+func formatChangeDelta(ch RecommendationChange) string {
+	switch {
+	case ch.From == "":
+		return "added"
+	case ch.To == "":
+		return "removed"
+	}
+	return fmt.Sprintf("%+.0f%%", ch.DeltaPercent)
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
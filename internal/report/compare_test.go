@@ -0,0 +1,114 @@
+package report
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/tabed23/k8s-resource-tuner/internal/models"
+	v1 "k8s.io/api/core/v1"
+)
+
+func priced(e models.ReportEntry, current, recommended float64) models.ReportEntry {
+	e.Cost = &models.Cost{Currency: "USD", Current: current, Recommended: recommended, Savings: current - recommended}
+	return e
+}
+
+func TestCompareRuns(t *testing.T) {
+	start := time.Date(2026, 10, 1, 8, 0, 0, 0, time.UTC)
+	from := models.Report{
+		RunID:     "20261001T080000Z-aaaaaa",
+		Timestamp: start,
+		Lookback:  "168h0m0s",
+		Entries: []models.ReportEntry{
+			priced(testEntry("shop", "api", 2,
+				testContainer{name: "app", current: requests("1", "1Gi"), recommended: requests("500m", "512Mi")}), 100, 60),
+			priced(testEntry("shop", "worker", 1,
+				testContainer{name: "app", current: requests("100m", "256Mi"), recommended: requests("200m", "256Mi")}), 20, 25),
+			priced(testEntry("shop", "old", 1,
+				testContainer{name: "app", current: requests("100m", "128Mi"), recommended: requests("50m", "128Mi")}), 30, 10),
+		},
+	}
+	to := models.Report{
+		RunID:     "20261008T080000Z-bbbbbb",
+		Timestamp: start.Add(7 * 24 * time.Hour),
+		Lookback:  "168h0m0s",
+		Entries: []models.ReportEntry{
+			priced(testEntry("shop", "api", 2,
+				testContainer{name: "app", current: requests("500m", "512Mi"), recommended: requests("500m", "512Mi")}), 60, 60),
+			priced(testEntry("shop", "worker", 1, testContainer{
+				name:        "app",
+				current:     requests("100m", "256Mi"),
+				recommended: models.ResourceConfig{Request: list("cpu", "300m", "memory", "262Mi"), Limits: list("memory", "512Mi")},
+			}), 20, 30),
+			priced(testEntry("shop", "new", 1,
+				testContainer{name: "app", current: requests("100m", "128Mi"), recommended: requests("100m", "128Mi")}), 40, 40),
+		},
+	}
+	for _, r := range []*models.Report{&from, &to} {
+		r.Summary = Summarize(r.Entries)
+	}
+
+	c := CompareRuns(from, to, 10)
+
+	if c.From.RunID != from.RunID || !c.To.Timestamp.Equal(to.Timestamp) || c.ThresholdPercent != 10 {
+		t.Errorf("runs = %+v -> %+v, threshold %g", c.From, c.To, c.ThresholdPercent)
+	}
+	if got := workloadNames(c.Appeared); !reflect.DeepEqual(got, []string{"new"}) {
+		t.Errorf("appeared = %v, want [new]", got)
+	}
+	if got := workloadNames(c.Disappeared); !reflect.DeepEqual(got, []string{"old"}) {
+		t.Errorf("disappeared = %v, want [old]", got)
+	}
+
+	wantChanged := []RecommendationChange{
+		{Namespace: "shop", Kind: models.KindDeployment, Workload: "worker", Container: "app",
+			Resource: v1.ResourceCPU, Field: "request", From: "200m", To: "300m", DeltaPercent: 50},
+		{Namespace: "shop", Kind: models.KindDeployment, Workload: "worker", Container: "app",
+			Resource: v1.ResourceMemory, Field: "limit", To: "512Mi"},
+	}
+	if !reflect.DeepEqual(c.Changed, wantChanged) {
+		t.Errorf("changed = %+v, want %+v", c.Changed, wantChanged)
+	}
+
+	if len(c.Applied) != 1 {
+		t.Fatalf("applied = %+v, want api only", c.Applied)
+	}
+	a := c.Applied[0]
+	if a.Name != "api" || a.CPUWasteBefore != 1 || a.MemoryWasteBefore != 1 ||
+		a.CPUWasteAfter != 0 || a.MemoryWasteAfter != 0 || !a.ReducedWaste {
+		t.Errorf("applied = %+v", a)
+	}
+	if a.Before.CPURequested != 2 || a.After.CPURequested != 1 {
+		t.Errorf("applied requests %g -> %g, want 2 -> 1", a.Before.CPURequested, a.After.CPURequested)
+	}
+
+	wantCost := &CostDelta{
+		Currency:    "USD",
+		FromCurrent: 150, ToCurrent: 120, Delta: -30,
+		FromRecommended: 95, ToRecommended: 130,
+		FromSavings: 55, ToSavings: -10,
+	}
+	if !reflect.DeepEqual(c.Cost, wantCost) {
+		t.Errorf("cost = %+v, want %+v", c.Cost, wantCost)
+	}
+}
+
+func TestCompareRunsWasteGrew(t *testing.T) {
+	before := testEntry("shop", "api", 1,
+		testContainer{name: "app", current: requests("500m", "1Gi"), recommended: requests("500m", "512Mi")})
+	after := testEntry("shop", "api", 1,
+		testContainer{name: "app", current: requests("1", "512Mi"), recommended: requests("500m", "512Mi")})
+
+	c := CompareRuns(models.Report{Entries: []models.ReportEntry{before}}, models.Report{Entries: []models.ReportEntry{after}}, 10)
+
+	if len(c.Applied) != 1 || c.Applied[0].ReducedWaste {
+		t.Errorf("applied = %+v, want a change that did not reduce waste", c.Applied)
+	}
+	if c.Cost != nil {
+		t.Errorf("cost = %+v, want none for unpriced runs", c.Cost)
+	}
+	if len(c.Changed) != 0 || len(c.Appeared) != 0 || len(c.Disappeared) != 0 {
+		t.Errorf("unexpected changes %+v", c)
+	}
+}
@@ -0,0 +1,123 @@
+package report
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/tabed23/k8s-resource-tuner/internal/models"
+	v1 "k8s.io/api/core/v1"
+)
+
+// csvRenderer writes one row per container with its current and
+// recommended requests and limits. Memory usage is in bytes, CPU in cores.
+// The summary totals go to a file of their own, so that both files are a
+// single table.
+type csvRenderer struct{}
+
+var csvHeader = []string{
+	"cluster", "namespace", "kind", "workload", "container", "role",
+	"current_cpu_request", "current_cpu_limit", "current_memory_request", "current_memory_limit",
+	"recommended_cpu_request", "recommended_cpu_limit", "recommended_memory_request", "recommended_memory_limit",
+	"cpu_p95", "cpu_p99", "memory_p95", "memory_p99", "violations",
+	"currency", "current_monthly_cost", "recommended_monthly_cost", "monthly_savings",
+}
+
+var csvSummaryHeader = []string{
+	"summary", "rank", "cluster", "namespace", "team", "kind", "workload",
+	"containers", "over_provisioned", "under_provisioned",
+	"cpu_requested_cores", "cpu_recommended_cores", "memory_requested_gib", "memory_recommended_gib",
+	"current_monthly_cost", "recommended_monthly_cost", "monthly_savings",
+}
+
+func (csvRenderer) Extension() string { return "csv" }
+
+func (csvRenderer) Render(w io.Writer, reportData models.Report, namespace string) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, e := range reportData.Entries {
+		for _, rec := range e.Recommendation {
+			current := containerResources(e.Workload, rec.ContainerName)
+			row := []string{
+				e.Cluster, e.Workload.Namespace, e.Workload.Kind, e.Workload.Name, rec.ContainerName,
+				string(containerRole(e.Workload, rec.ContainerName)),
+				quantityString(current.Request, v1.ResourceCPU), quantityString(current.Limits, v1.ResourceCPU),
+				quantityString(current.Request, v1.ResourceMemory), quantityString(current.Limits, v1.ResourceMemory),
+				quantityString(rec.RecommendedRequest.Request, v1.ResourceCPU), quantityString(rec.RecommendedLimit.Limits, v1.ResourceCPU),
+				quantityString(rec.RecommendedRequest.Request, v1.ResourceMemory), quantityString(rec.RecommendedLimit.Limits, v1.ResourceMemory),
+			}
+			if u := rec.UsageStats; u != nil {
+				row = append(row, formatFloat(u.CPUP95), formatFloat(u.CPUP99), formatFloat(u.MemP95), formatFloat(u.MemP99))
+			} else {
+				row = append(row, "", "", "", "")
+			}
+			row = append(row, strings.Join(rec.Violations, "; "))
+			if c := rec.Cost; c != nil {
+				row = append(row, c.Currency, formatFloat(c.Current), formatFloat(c.Recommended), formatFloat(c.Savings))
+			} else {
+				row = append(row, "", "", "", "")
+			}
+			if err := cw.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// RenderSummary writes the summary with one row per scope and per ranked
+// workload.
+func (csvRenderer) RenderSummary(w io.Writer, reportData models.Report) error {
+	s := reportData.Summary
+	cw := csv.NewWriter(w)
+	cw.Write(csvSummaryHeader)
+	row := func(scope string, rank int, cluster, namespace, team, kind, workload string, t models.Totals) {
+		r := []string{scope, "", cluster, namespace, team, kind, workload,
+			strconv.Itoa(t.Containers), strconv.Itoa(t.OverProvisioned), strconv.Itoa(t.UnderProvisioned),
+			formatFloat(t.CPURequested), formatFloat(t.CPURecommended),
+			formatFloat(t.MemoryRequested), formatFloat(t.MemoryRecommended),
+			"", "", ""}
+		if rank > 0 {
+			r[1] = strconv.Itoa(rank)
+		}
+		if s.Currency != "" {
+			r[14], r[15], r[16] = formatFloat(t.CurrentCost), formatFloat(t.RecommendedCost), formatFloat(t.Savings)
+		}
+		cw.Write(r)
+	}
+	row("total", 0, "", "", "", "", "", s.Total)
+	for _, c := range s.Clusters {
+		row("cluster", 0, c.Cluster, "", "", "", "", c.Totals)
+	}
+	for _, ns := range s.Namespaces {
+		row("namespace", 0, ns.Cluster, ns.Namespace, "", "", "", ns.Totals)
+	}
+	for _, t := range s.Teams {
+		row("team", 0, "", "", t.Team, "", "", t.Totals)
+	}
+	for i, wl := range s.TopWasteful {
+		row("wasteful", i+1, wl.Cluster, wl.Namespace, "", wl.Kind, wl.Name, wl.Totals)
+	}
+	for i, wl := range s.TopAtRisk {
+		row("at_risk", i+1, wl.Cluster, wl.Namespace, "", wl.Kind, wl.Name, wl.Totals)
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// quantityString returns the value of a resource, empty when it is unset.
+func quantityString(list v1.ResourceList, name v1.ResourceName) string {
+	q, ok := list[name]
+	if !ok {
+		return ""
+	}
+	return q.String()
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
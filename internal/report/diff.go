@@ -0,0 +1,93 @@
+package report
+
+import (
+	"math"
+
+	"github.com/tabed23/k8s-resource-tuner/internal/models"
+	v1 "k8s.io/api/core/v1"
+)
+
+// Change compares one resource value of a container's current spec with
+// the recommendation. Values are in cores for CPU and bytes for memory.
+type Change struct {
+	Cluster      string          `json:"cluster,omitempty"`
+	Namespace    string          `json:"namespace"`
+	Kind         string          `json:"kind"`
+	Workload     string          `json:"workload"`
+	Container    string          `json:"container"`
+	Resource     v1.ResourceName `json:"resource"`
+	Field        string          `json:"field"` // "request" or "limit"
+	Current      string          `json:"current,omitempty"`
+	Recommended  string          `json:"recommended,omitempty"`
+	Delta        float64         `json:"delta"`
+	DeltaPercent float64         `json:"delta_percent"`
+}
+
+// Compare lists the CPU and memory request and limit of every container of
+// the entry next to their recommended values.
+func Compare(entry models.ReportEntry) []Change {
+	var changes []Change
+	for _, rec := range entry.Recommendation {
+		var current models.ResourceConfig
+		for _, c := range entry.Workload.Containers {
+			if c.Name == rec.ContainerName {
+				current = c.Resources
+			}
+		}
+		for _, name := range []v1.ResourceName{v1.ResourceCPU, v1.ResourceMemory} {
+			changes = append(changes,
+				newChange(entry, rec.ContainerName, name, "request", current.Request, rec.RecommendedRequest.Request),
+				newChange(entry, rec.ContainerName, name, "limit", current.Limits, rec.RecommendedLimit.Limits))
+		}
+	}
+	return changes
+}
+
+func newChange(entry models.ReportEntry, container string, name v1.ResourceName, field string, current, recommended v1.ResourceList) Change {
+	c := Change{
+		Cluster:   entry.Cluster,
+		Namespace: entry.Workload.Namespace,
+		Kind:      entry.Workload.Kind,
+		Workload:  entry.Workload.Name,
+		Container: container,
+		Resource:  name,
+		Field:     field,
+	}
+	var cur, rec float64
+	if q, ok := current[name]; ok {
+		c.Current = q.String()
+		cur = q.AsApproximateFloat64()
+	}
+	if q, ok := recommended[name]; ok {
+		c.Recommended = q.String()
+		rec = q.AsApproximateFloat64()
+	}
+	c.Delta = rec - cur
+	if cur > 0 {
+		c.DeltaPercent = c.Delta / cur * 100
+	}
+	return c
+}
+
+// Exceeds reports whether the change is worth acting on: a value is added
+// or removed, or moves by more than thresholdPercent.
+func (c Change) Exceeds(thresholdPercent float64) bool {
+	if c.Current == c.Recommended {
+		return false
+	}
+	if c.Current == "" || c.Recommended == "" {
+		return true
+	}
+	return math.Abs(c.DeltaPercent) > thresholdPercent
+}
+
+// NeedsChange reports whether any value of the entry changes by more than
+// thresholdPercent.
+func NeedsChange(entry models.ReportEntry, thresholdPercent float64) bool {
+	for _, c := range Compare(entry) {
+		if c.Exceeds(thresholdPercent) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,484 @@
+package report
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tabed23/k8s-resource-tuner/internal/models"
+	v1 "k8s.io/api/core/v1"
+)
+
+// htmlRenderer writes a single self-contained HTML file: charts are inline
+// SVG and sorting and filtering are plain JavaScript, so the report works
+// offline without any CDN.
+type htmlRenderer struct{}
+
+func (htmlRenderer) Extension() string { return "html" }
+
+// htmlRow is one container of the summary table and of the detail charts.
+type htmlRow struct {
+	Cluster, Namespace, Kind, Workload, Container string
+	CPURequest, CPURecommended                    htmlValue
+	MemRequest, MemRecommended                    htmlValue
+	CPUP95, MemP95                                htmlValue
+	Cost, Savings                                 htmlValue
+	Reason                                        string
+	Violations                                    []string
+	CPUChart, MemChart                            template.HTML
+}
+
+// htmlValue is a table cell with the number it sorts by.
+type htmlValue struct {
+	Text string
+	Sort float64
+}
+
+func (htmlRenderer) Render(w io.Writer, reportData models.Report, namespace string) error {
+	var rows []htmlRow
+	namespaces := map[string]bool{}
+	window := reportWindow(reportData)
+	for _, e := range reportData.Entries {
+		namespaces[e.Workload.Namespace] = true
+		for _, rec := range e.Recommendation {
+			current := containerResources(e.Workload, rec.ContainerName)
+			row := htmlRow{
+				Cluster:        e.Cluster,
+				Namespace:      e.Workload.Namespace,
+				Kind:           e.Workload.Kind,
+				Workload:       e.Workload.Name,
+				Container:      rec.ContainerName,
+				CPURequest:     quantityValue(current.Request, v1.ResourceCPU),
+				CPURecommended: quantityValue(rec.RecommendedRequest.Request, v1.ResourceCPU),
+				MemRequest:     quantityValue(current.Request, v1.ResourceMemory),
+				MemRecommended: quantityValue(rec.RecommendedRequest.Request, v1.ResourceMemory),
+				Reason:         rec.Reason,
+				Violations:     rec.Violations,
+			}
+			row.Cost, row.Savings = htmlValue{Text: "-"}, htmlValue{Text: "-"}
+			if c := rec.Cost; c != nil {
+				row.Cost = htmlValue{formatMoney(c.Current), c.Current}
+				row.Savings = htmlValue{formatMoney(c.Savings), c.Savings}
+			}
+			var cpuSeries, memSeries []models.Point
+			if u := rec.UsageStats; u != nil {
+				row.CPUP95 = htmlValue{fmt.Sprintf("%.3f", u.CPUP95), u.CPUP95}
+				row.MemP95 = htmlValue{fmt.Sprintf("%.1fMi", u.MemP95/mebibyte), u.MemP95}
+				cpuSeries, memSeries = u.CPUSeries, u.MemSeries
+			}
+			row.CPUChart = svgChart("CPU (cores)", chartSegments(cpuSeries, 1, window, 0), window, markers(current, rec, v1.ResourceCPU, 1))
+			row.MemChart = svgChart("Memory (MiB)", chartSegments(memSeries, mebibyte, window, 0), window, markers(current, rec, v1.ResourceMemory, mebibyte))
+			rows = append(rows, row)
+		}
+	}
+	names := make([]string, 0, len(namespaces))
+	for ns := range namespaces {
+		names = append(names, ns)
+	}
+	sort.Strings(names)
+
+	return htmlTemplate.Execute(w, map[string]interface{}{
+		"Report":     reportData,
+		"Namespace":  namespace,
+		"Namespaces": names,
+		"Rows":       rows,
+	})
+}
+
+// htmlTotals and htmlRanking carry the currency of the report, empty when
+// it is not priced, into the summary templates.
+type htmlTotals struct {
+	models.Totals
+	Currency string
+}
+
+type htmlRanking struct {
+	Workloads []models.WorkloadTotals
+	Currency  string
+}
+
+const mebibyte = 1024 * 1024
+
+func quantityValue(list v1.ResourceList, name v1.ResourceName) htmlValue {
+	q, ok := list[name]
+	if !ok {
+		return htmlValue{Text: "-"}
+	}
+	return htmlValue{Text: q.String(), Sort: q.AsApproximateFloat64()}
+}
+
+// marker is a horizontal line drawn over a usage chart.
+type marker struct {
+	label  string
+	value  float64
+	color  string
+	dashed bool
+}
+
+// markers returns the current and recommended request and limit of one
+// resource, divided by scale.
+func markers(current models.ResourceConfig, rec models.Recommendation, name v1.ResourceName, scale float64) []marker {
+	var ms []marker
+	add := func(list v1.ResourceList, label, color string, dashed bool) {
+		if q, ok := list[name]; ok {
+			ms = append(ms, marker{label, q.AsApproximateFloat64() / scale, color, dashed})
+		}
+	}
+	add(current.Request, "current request", "#1f77b4", true)
+	add(current.Limits, "current limit", "#d62728", true)
+	add(rec.RecommendedRequest.Request, "recommended request", "#2ca02c", false)
+	add(rec.RecommendedLimit.Limits, "recommended limit", "#ff7f0e", false)
+	return ms
+}
+
+// timeWindow is the span of the time axis of the usage charts.
+type timeWindow struct {
+	start, end time.Time
+}
+
+// reportWindow returns the lookback window of the report, or the zero
+// window when the report does not tell its lookback.
+func reportWindow(r models.Report) timeWindow {
+	lookback, err := time.ParseDuration(r.Lookback)
+	if err != nil || r.Timestamp.IsZero() {
+		return timeWindow{}
+	}
+	return timeWindow{r.Timestamp.Add(-lookback), r.Timestamp}
+}
+
+// chartPoint is a point of a usage chart, x from 0 to 1 across the window.
+type chartPoint struct {
+	x, v float64
+}
+
+// chartSegments places the series on the window, divided by scale, and
+// splits it where steps are missing so that the gaps stay visible. Series of
+// more than maxPoints points are reduced to the maximum of each time bucket,
+// which keeps the peaks visible; zero keeps every point. A zero window spans
+// the series.
+func chartSegments(series []models.Point, scale float64, w timeWindow, maxPoints int) [][]chartPoint {
+	if len(series) == 0 {
+		return nil
+	}
+	if w.start.IsZero() || !w.end.After(w.start) {
+		w = timeWindow{series[0].Timestamp, series[len(series)-1].Timestamp}
+	}
+	span := w.end.Sub(w.start)
+	if span <= 0 {
+		return [][]chartPoint{{{0.5, series[0].Value / scale}}}
+	}
+	if maxPoints > 0 && len(series) > maxPoints {
+		if width := span / time.Duration(maxPoints); width > 0 {
+			series = bucketMax(series, w.start, width)
+		}
+	}
+
+	var step time.Duration
+	for i := 1; i < len(series); i++ {
+		if d := series[i].Timestamp.Sub(series[i-1].Timestamp); d > 0 && (step == 0 || d < step) {
+			step = d
+		}
+	}
+	var segments [][]chartPoint
+	for i, p := range series {
+		if i == 0 || (step > 0 && p.Timestamp.Sub(series[i-1].Timestamp) > 2*step) {
+			segments = append(segments, nil)
+		}
+		x := math.Min(1, math.Max(0, float64(p.Timestamp.Sub(w.start))/float64(span)))
+		segments[len(segments)-1] = append(segments[len(segments)-1], chartPoint{x, p.Value / scale})
+	}
+	return segments
+}
+
+// bucketMax reduces the series to the maximum of each bucket of the given
+// width, timed at the start of the bucket.
+func bucketMax(series []models.Point, start time.Time, width time.Duration) []models.Point {
+	var out []models.Point
+	for _, p := range series {
+		t := start.Add(p.Timestamp.Sub(start) / width * width)
+		if n := len(out); n > 0 && out[n-1].Timestamp.Equal(t) {
+			out[n-1].Value = math.Max(out[n-1].Value, p.Value)
+			continue
+		}
+		out = append(out, models.Point{Timestamp: t, Value: p.Value})
+	}
+	return out
+}
+
+// segmentsMax returns the highest value of the segments and markers.
+func segmentsMax(segments [][]chartPoint, ms []marker) float64 {
+	max := 0.0
+	for _, seg := range segments {
+		for _, p := range seg {
+			max = math.Max(max, p.v)
+		}
+	}
+	for _, m := range ms {
+		max = math.Max(max, m.value)
+	}
+	if max == 0 {
+		max = 1
+	}
+	return max * 1.1
+}
+
+// formatChartTime labels the ends of the time axis.
+func formatChartTime(t time.Time) string {
+	return t.Format("Jan 2 15:04")
+}
+
+// svgChart draws the usage over the lookback window, a line per run of
+// consecutive steps, with the markers as horizontal lines.
+func svgChart(title string, segments [][]chartPoint, window timeWindow, ms []marker) template.HTML {
+	const width, height, left, right, top, bottom = 480.0, 200.0, 50.0, 10.0, 20.0, 20.0
+	plotW, plotH := width-left-right, height-top-bottom
+
+	max := segmentsMax(segments, ms)
+	x := func(f float64) float64 { return left + f*plotW }
+	y := func(v float64) float64 { return top + plotH - v/max*plotH }
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg class="chart" viewBox="0 0 %.0f %.0f" xmlns="http://www.w3.org/2000/svg" role="img">`, width, height)
+	fmt.Fprintf(&b, `<text x="%.0f" y="14" class="title">%s</text>`, left, template.HTMLEscapeString(title))
+	fmt.Fprintf(&b, `<line x1="%.0f" y1="%.0f" x2="%.0f" y2="%.0f" class="axis"/>`, left, top, left, top+plotH)
+	fmt.Fprintf(&b, `<line x1="%.0f" y1="%.0f" x2="%.0f" y2="%.0f" class="axis"/>`, left, top+plotH, left+plotW, top+plotH)
+	for _, v := range []float64{0, max / 2, max} {
+		fmt.Fprintf(&b, `<text x="%.0f" y="%.1f" class="tick" text-anchor="end">%s</text>`, left-4, y(v)+3, formatTick(v))
+	}
+	if !window.start.IsZero() {
+		fmt.Fprintf(&b, `<text x="%.0f" y="%.0f" class="tick">%s</text>`, left, top+plotH+13, formatChartTime(window.start))
+		fmt.Fprintf(&b, `<text x="%.0f" y="%.0f" class="tick" text-anchor="end">%s</text>`, left+plotW, top+plotH+13, formatChartTime(window.end))
+	}
+
+	for _, seg := range segments {
+		if len(seg) == 1 {
+			fmt.Fprintf(&b, `<circle cx="%.1f" cy="%.1f" r="1.5" class="usage-point"/>`, x(seg[0].x), y(seg[0].v))
+			continue
+		}
+		points := make([]string, len(seg))
+		for i, p := range seg {
+			points[i] = fmt.Sprintf("%.1f,%.1f", x(p.x), y(p.v))
+		}
+		fmt.Fprintf(&b, `<polyline points="%s" class="usage"/>`, strings.Join(points, " "))
+	}
+	if len(segments) == 0 {
+		fmt.Fprintf(&b, `<text x="%.0f" y="%.0f" class="tick" text-anchor="middle">no usage samples</text>`, left+plotW/2, top+plotH/2)
+	}
+
+	for _, m := range ms {
+		dash := ""
+		if m.dashed {
+			dash = ` stroke-dasharray="6 4"`
+		}
+		fmt.Fprintf(&b, `<line x1="%.0f" y1="%.1f" x2="%.0f" y2="%.1f" stroke="%s"%s><title>%s: %s</title></line>`,
+			left, y(m.value), left+plotW, y(m.value), m.color, dash, m.label, formatTick(m.value))
+	}
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
+
+func formatTick(v float64) string {
+	switch {
+	case v >= 100:
+		return fmt.Sprintf("%.0f", v)
+	case v >= 1:
+		return fmt.Sprintf("%.2f", v)
+	default:
+		return fmt.Sprintf("%.3f", v)
+	}
+}
+
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"scopeName":    scopeName,
+	"workloadName": workloadName,
+	"cores":        formatCores,
+	"gib":          formatGiB,
+	"money":        formatMoney,
+	"teamName":     teamName,
+	"costHeader":   costHeader,
+	"inc":          func(i int) int { return i + 1 },
+	"totals": func(t models.Totals, currency string) htmlTotals {
+		return htmlTotals{t, currency}
+	},
+	"ranking": func(w []models.WorkloadTotals, currency string) htmlRanking {
+		return htmlRanking{w, currency}
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Kubernetes Resource Usage Report</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; width: 100%; font-size: 0.9em; }
+th, td { border: 1px solid #ddd; padding: 4px 8px; text-align: left; }
+th { background: #f3f3f3; cursor: pointer; user-select: none; }
+th.asc::after { content: " \25B2"; }
+th.desc::after { content: " \25BC"; }
+td.num { text-align: right; }
+section.container { border-top: 1px solid #ddd; margin-top: 1.5em; padding-top: 0.5em; }
+.charts { display: flex; flex-wrap: wrap; gap: 1em; }
+.chart { width: 480px; max-width: 100%; }
+.chart .axis { stroke: #888; }
+.chart .tick, .chart .title { font-size: 10px; fill: #555; }
+.chart .title { font-weight: bold; }
+.chart .usage { fill: none; stroke: #555; stroke-width: 1.2; }
+.chart .usage-point { fill: #555; }
+.legend span { display: inline-block; margin-right: 1.5em; font-size: 0.85em; }
+.legend i { display: inline-block; width: 20px; height: 0; border-top: 2px solid; vertical-align: middle; margin-right: 4px; }
+.violation { color: #c00; font-weight: bold; }
+.reason { font-style: italic; color: #555; font-size: 0.85em; }
+table.totals { margin-bottom: 1em; }
+table.totals th { cursor: default; }
+</style>
+</head>
+<body>
+<h1>Kubernetes Resource Usage Report</h1>
+<p>Generated on {{.Report.Timestamp.Format "2006-01-02 15:04:05"}}
+{{- if .Report.Lookback}} from the usage of the last {{.Report.Lookback}}{{end}}.
+{{- if .Report.Clusters}} Clusters: {{range $i, $c := .Report.Clusters}}{{if $i}}, {{end}}{{$c}}{{end}}.{{end}}
+{{- if .Report.RunID}} Run {{.Report.RunID}}.{{end}}</p>
+
+<p><label>Namespace:
+<select id="namespace-filter">
+<option value="">All</option>
+{{- range .Namespaces}}
+<option value="{{.}}">{{.}}</option>
+{{- end}}
+</select></label></p>
+
+{{with .Report.Summary}}{{if .Total.Containers}}{{$cur := .Currency}}<h2>Summary</h2>
+<table class="totals">
+<thead><tr>
+<th>Scope</th><th>Containers</th><th>Over-provisioned</th><th>Under-provisioned</th>
+<th>CPU requested</th><th>CPU recommended</th><th>Memory requested</th><th>Memory recommended</th>
+{{- if $cur}}{{range costHeader $cur}}<th>{{.}}</th>{{end}}{{end}}
+</tr></thead>
+<tbody>
+<tr><td><b>Total</b></td>{{template "totals" (totals .Total $cur)}}</tr>
+{{- range .Clusters}}
+<tr><td>{{scopeName .}}</td>{{template "totals" (totals .Totals $cur)}}</tr>
+{{- end}}
+{{- range .Namespaces}}
+<tr data-namespace="{{.Namespace}}"><td>{{scopeName .}}</td>{{template "totals" (totals .Totals $cur)}}</tr>
+{{- end}}
+{{- range .Teams}}
+<tr><td>{{teamName .}}</td>{{template "totals" (totals .Totals $cur)}}</tr>
+{{- end}}
+</tbody>
+</table>
+{{- if .TopWasteful}}
+<h3>Most wasteful workloads</h3>
+{{template "ranking" (ranking .TopWasteful $cur)}}
+{{- end}}
+{{- if .TopAtRisk}}
+<h3>Most at-risk workloads</h3>
+{{template "ranking" (ranking .TopAtRisk $cur)}}
+{{- end}}
+{{end}}{{end}}
+
+<h2>Recommendations</h2>
+<table id="summary">
+<thead><tr>
+<th>Cluster</th><th>Namespace</th><th>Workload</th><th>Container</th>
+<th data-type="num">CPU request</th><th data-type="num">Recommended CPU</th><th data-type="num">CPU p95</th>
+<th data-type="num">Memory request</th><th data-type="num">Recommended memory</th><th data-type="num">Memory p95</th>
+{{- with .Report.Summary.Currency}}
+<th data-type="num">Monthly cost ({{.}})</th><th data-type="num">Monthly savings ({{.}})</th>
+{{- end}}
+</tr></thead>
+<tbody>
+{{- range $i, $r := .Rows}}
+<tr data-namespace="{{$r.Namespace}}">
+<td>{{$r.Cluster}}</td><td>{{$r.Namespace}}</td><td><a href="#c{{$i}}">{{$r.Kind}} {{$r.Workload}}</a></td><td>{{$r.Container}}</td>
+<td class="num" data-sort="{{$r.CPURequest.Sort}}">{{$r.CPURequest.Text}}</td>
+<td class="num" data-sort="{{$r.CPURecommended.Sort}}">{{$r.CPURecommended.Text}}</td>
+<td class="num" data-sort="{{$r.CPUP95.Sort}}">{{$r.CPUP95.Text}}</td>
+<td class="num" data-sort="{{$r.MemRequest.Sort}}">{{$r.MemRequest.Text}}</td>
+<td class="num" data-sort="{{$r.MemRecommended.Sort}}">{{$r.MemRecommended.Text}}</td>
+<td class="num" data-sort="{{$r.MemP95.Sort}}">{{$r.MemP95.Text}}</td>
+{{- if $.Report.Summary.Currency}}
+<td class="num" data-sort="{{$r.Cost.Sort}}">{{$r.Cost.Text}}</td>
+<td class="num" data-sort="{{$r.Savings.Sort}}">{{$r.Savings.Text}}</td>
+{{- end}}
+</tr>
+{{- end}}
+</tbody>
+</table>
+
+<h2>Usage</h2>
+<p class="legend">
+<span><i style="border-color:#555"></i>usage, highest pod</span>
+<span><i style="border-color:#1f77b4;border-top-style:dashed"></i>current request</span>
+<span><i style="border-color:#d62728;border-top-style:dashed"></i>current limit</span>
+<span><i style="border-color:#2ca02c"></i>recommended request</span>
+<span><i style="border-color:#ff7f0e"></i>recommended limit</span>
+</p>
+{{- range $i, $r := .Rows}}
+<section class="container" id="c{{$i}}" data-namespace="{{$r.Namespace}}">
+<h3>{{if $r.Cluster}}{{$r.Cluster}} / {{end}}{{$r.Namespace}} / {{$r.Kind}} {{$r.Workload}} / {{$r.Container}}</h3>
+<div class="charts">{{$r.CPUChart}}{{$r.MemChart}}</div>
+{{- range $r.Violations}}
+<p class="violation">Policy violation: {{.}}</p>
+{{- end}}
+<p class="reason">{{$r.Reason}}</p>
+</section>
+{{- end}}
+
+<script>
+(function () {
+  var filter = document.getElementById("namespace-filter");
+  filter.addEventListener("change", function () {
+    document.querySelectorAll("[data-namespace]").forEach(function (el) {
+      el.style.display = !filter.value || el.getAttribute("data-namespace") === filter.value ? "" : "none";
+    });
+  });
+
+  var table = document.getElementById("summary");
+  table.querySelectorAll("th").forEach(function (th, col) {
+    th.addEventListener("click", function () {
+      var asc = !th.classList.contains("asc");
+      table.querySelectorAll("th").forEach(function (h) { h.classList.remove("asc", "desc"); });
+      th.classList.add(asc ? "asc" : "desc");
+      var numeric = th.getAttribute("data-type") === "num";
+      var body = table.tBodies[0];
+      var rows = Array.prototype.slice.call(body.rows);
+      rows.sort(function (a, b) {
+        var x = a.cells[col], y = b.cells[col], d;
+        if (numeric) {
+          d = parseFloat(x.getAttribute("data-sort")) - parseFloat(y.getAttribute("data-sort"));
+        } else {
+          d = x.textContent.localeCompare(y.textContent);
+        }
+        return asc ? d : -d;
+      });
+      rows.forEach(function (r) { body.appendChild(r); });
+    });
+  });
+})();
+</script>
+</body>
+</html>
+{{- define "totals"}}<td class="num">{{.Containers}}</td><td class="num">{{.OverProvisioned}}</td><td class="num">{{.UnderProvisioned}}</td>
+<td class="num">{{cores .CPURequested}}</td><td class="num">{{cores .CPURecommended}}</td>
+<td class="num">{{gib .MemoryRequested}}</td><td class="num">{{gib .MemoryRecommended}}</td>
+{{- if .Currency}}
+<td class="num">{{money .CurrentCost}}</td><td class="num">{{money .RecommendedCost}}</td><td class="num">{{money .Savings}}</td>
+{{- end}}{{end}}
+{{- define "ranking"}}{{$cur := .Currency}}<table class="totals">
+<thead><tr><th>#</th><th>Workload</th><th>CPU requested</th><th>CPU recommended</th><th>Memory requested</th><th>Memory recommended</th>
+{{- if $cur}}<th>Monthly savings ({{$cur}})</th>{{end}}</tr></thead>
+<tbody>
+{{- range $i, $w := .Workloads}}
+<tr data-namespace="{{$w.Namespace}}"><td class="num">{{inc $i}}</td><td>{{workloadName $w}}</td>
+<td class="num">{{cores $w.CPURequested}}</td><td class="num">{{cores $w.CPURecommended}}</td>
+<td class="num">{{gib $w.MemoryRequested}}</td><td class="num">{{gib $w.MemoryRecommended}}</td>
+{{- if $cur}}<td class="num">{{money $w.Savings}}</td>{{end}}</tr>
+{{- end}}
+</tbody>
+</table>{{end}}
+`))
@@ -0,0 +1,33 @@
+package report
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/tabed23/k8s-resource-tuner/internal/models"
+	"sigs.k8s.io/yaml"
+)
+
+type jsonRenderer struct{}
+
+func (jsonRenderer) Extension() string { return "json" }
+
+func (jsonRenderer) Render(w io.Writer, reportData models.Report, namespace string) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(reportData)
+}
+
+// yamlRenderer writes the same document as jsonRenderer, as YAML.
+type yamlRenderer struct{}
+
+func (yamlRenderer) Extension() string { return "yaml" }
+
+func (yamlRenderer) Render(w io.Writer, reportData models.Report, namespace string) error {
+	b, err := yaml.Marshal(reportData)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
@@ -0,0 +1,282 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/tabed23/k8s-resource-tuner/internal/models"
+	v1 "k8s.io/api/core/v1"
+)
+
+// markdownRenderer writes the report as GitHub flavored Markdown, for pull
+// request comments and wikis. Each cell shows the current value followed by
+// the recommended one.
+type markdownRenderer struct{}
+
+func (markdownRenderer) Extension() string { return "md" }
+
+func (markdownRenderer) Render(w io.Writer, reportData models.Report, namespace string) error {
+	var b strings.Builder
+	b.WriteString("# Kubernetes Resource Usage Report\n\n")
+	fmt.Fprintf(&b, "- Generated on: %s\n", reportData.Timestamp.Format("2006-01-02 15:04:05"))
+	if namespace == "ALL_NAMESPACES" {
+		b.WriteString("- Namespaces: all scanned namespaces\n")
+	} else {
+		fmt.Fprintf(&b, "- Namespace: %s\n", namespace)
+	}
+	if len(reportData.Clusters) > 0 {
+		fmt.Fprintf(&b, "- Clusters: %s\n", strings.Join(reportData.Clusters, ", "))
+	}
+	if reportData.Lookback != "" {
+		fmt.Fprintf(&b, "- Lookback: %s\n", reportData.Lookback)
+	}
+	if reportData.RunID != "" {
+		fmt.Fprintf(&b, "- Run: %s\n", reportData.RunID)
+	}
+
+	writeMarkdownSummary(&b, reportData.Summary)
+
+	b.WriteString("\n## Recommendations\n")
+	currency := reportData.Summary.Currency
+	section := ""
+	var violations []string
+	for _, e := range reportData.Entries {
+		title := e.Workload.Namespace
+		if e.Cluster != "" {
+			title = e.Cluster + " / " + title
+		}
+		if title != section {
+			section = title
+			writeViolations(&b, violations)
+			violations = nil
+			fmt.Fprintf(&b, "\n### %s\n\n", markdownEscape(title))
+			header := []string{"Workload", "Container", "CPU request", "CPU limit", "Memory request", "Memory limit"}
+			if currency != "" {
+				header = append(header, fmt.Sprintf("Monthly cost (%s)", currency))
+			}
+			writeMarkdownHeader(&b, header)
+		}
+		workload := fmt.Sprintf("%s %s", e.Workload.Kind, e.Workload.Name)
+		for _, rec := range e.Recommendation {
+			current := containerResources(e.Workload, rec.ContainerName)
+			cells := []string{
+				markdownEscape(workload), markdownEscape(rec.ContainerName),
+				markdownChange(current.Request, rec.RecommendedRequest.Request, v1.ResourceCPU),
+				markdownChange(current.Limits, rec.RecommendedLimit.Limits, v1.ResourceCPU),
+				markdownChange(current.Request, rec.RecommendedRequest.Request, v1.ResourceMemory),
+				markdownChange(current.Limits, rec.RecommendedLimit.Limits, v1.ResourceMemory),
+			}
+			if currency != "" {
+				cells = append(cells, markdownCost(rec.Cost))
+			}
+			fmt.Fprintf(&b, "| %s |\n", strings.Join(cells, " | "))
+			for _, v := range rec.Violations {
+				violations = append(violations, fmt.Sprintf("%s / %s: %s", workload, rec.ContainerName, v))
+			}
+		}
+	}
+	writeViolations(&b, violations)
+
+	if len(reportData.Comparisons) > 0 {
+		b.WriteString("\n## Cross-Cluster Comparison\n\n")
+		b.WriteString("| Workload | Container | Cluster | CPU request / limit | Memory request / limit |\n")
+		b.WriteString("|---|---|---|---|---|\n")
+		for _, c := range reportData.Comparisons {
+			for _, cr := range c.Clusters {
+				fmt.Fprintf(&b, "| %s | %s | %s | %s / %s | %s / %s |\n",
+					markdownEscape(fmt.Sprintf("%s %s/%s", c.Kind, c.Namespace, c.Name)), markdownEscape(c.Container), markdownEscape(cr.Cluster),
+					dashIfEmpty(quantityString(cr.Request, v1.ResourceCPU)), dashIfEmpty(quantityString(cr.Limit, v1.ResourceCPU)),
+					dashIfEmpty(quantityString(cr.Request, v1.ResourceMemory)), dashIfEmpty(quantityString(cr.Limit, v1.ResourceMemory)))
+			}
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeMarkdownSummary renders the totals and the rankings of the summary,
+// with their monthly costs when the report is priced.
+func writeMarkdownSummary(b *strings.Builder, s models.Summary) {
+	if s.Total.Containers == 0 {
+		return
+	}
+	b.WriteString("\n## Summary\n\n")
+	header := []string{"Scope", "Containers", "Over-provisioned", "Under-provisioned", "CPU requested", "CPU recommended", "Memory requested", "Memory recommended"}
+	if s.Currency != "" {
+		header = append(header, costHeader(s.Currency)...)
+	}
+	writeMarkdownHeader(b, header)
+	row := func(scope string, t models.Totals) {
+		cells := []string{scope, fmt.Sprint(t.Containers), fmt.Sprint(t.OverProvisioned), fmt.Sprint(t.UnderProvisioned),
+			formatCores(t.CPURequested), formatCores(t.CPURecommended),
+			formatGiB(t.MemoryRequested), formatGiB(t.MemoryRecommended)}
+		if s.Currency != "" {
+			cells = append(cells, formatMoney(t.CurrentCost), formatMoney(t.RecommendedCost), formatMoney(t.Savings))
+		}
+		fmt.Fprintf(b, "| %s |\n", strings.Join(cells, " | "))
+	}
+	row("**Total**", s.Total)
+	for _, c := range s.Clusters {
+		row(markdownEscape(scopeName(c)), c.Totals)
+	}
+	for _, ns := range s.Namespaces {
+		row(markdownEscape(scopeName(ns)), ns.Totals)
+	}
+	for _, t := range s.Teams {
+		row(markdownEscape(teamName(t)), t.Totals)
+	}
+
+	for _, ranking := range []struct {
+		title     string
+		workloads []models.WorkloadTotals
+	}{{"Most wasteful workloads", s.TopWasteful}, {"Most at-risk workloads", s.TopAtRisk}} {
+		if len(ranking.workloads) == 0 {
+			continue
+		}
+		fmt.Fprintf(b, "\n**%s**\n\n", ranking.title)
+		header := []string{"#", "Workload", "CPU requested → recommended", "Memory requested → recommended"}
+		if s.Currency != "" {
+			header = append(header, fmt.Sprintf("Monthly savings (%s)", s.Currency))
+		}
+		writeMarkdownHeader(b, header)
+		for i, w := range ranking.workloads {
+			cells := []string{fmt.Sprint(i + 1), markdownEscape(workloadName(w)),
+				fmt.Sprintf("%s → %s", formatCores(w.CPURequested), formatCores(w.CPURecommended)),
+				fmt.Sprintf("%s → %s", formatGiB(w.MemoryRequested), formatGiB(w.MemoryRecommended))}
+			if s.Currency != "" {
+				cells = append(cells, formatMoney(w.Savings))
+			}
+			fmt.Fprintf(b, "| %s |\n", strings.Join(cells, " | "))
+		}
+	}
+}
+
+// ComparisonMarkdown writes the comparison of two runs as GitHub flavored
+// Markdown.
+func ComparisonMarkdown(w io.Writer, c RunComparison) error {
+	var b strings.Builder
+	b.WriteString("# Kubernetes Resource Recommendation Changes\n\n")
+	fmt.Fprintf(&b, "- From: %s\n", runLabel(c.From))
+	fmt.Fprintf(&b, "- To: %s\n", runLabel(c.To))
+	fmt.Fprintf(&b, "- Threshold: %g%%\n", c.ThresholdPercent)
+
+	if c.Cost != nil {
+		fmt.Fprintf(&b, "\n## Monthly cost (%s)\n\n", c.Cost.Currency)
+		writeMarkdownHeader(&b, []string{"", "From", "To", "Delta"})
+		for _, r := range []struct {
+			name     string
+			from, to float64
+		}{
+			{"Current", c.Cost.FromCurrent, c.Cost.ToCurrent},
+			{"Recommended", c.Cost.FromRecommended, c.Cost.ToRecommended},
+			{"Savings", c.Cost.FromSavings, c.Cost.ToSavings},
+		} {
+			fmt.Fprintf(&b, "| %s | %s | %s | %+.2f |\n", r.name, formatMoney(r.from), formatMoney(r.to), r.to-r.from)
+		}
+	}
+
+	for _, list := range []struct {
+		title     string
+		workloads []models.WorkloadTotals
+	}{{"Appeared workloads", c.Appeared}, {"Disappeared workloads", c.Disappeared}} {
+		fmt.Fprintf(&b, "\n## %s (%d)\n\n", list.title, len(list.workloads))
+		if len(list.workloads) == 0 {
+			b.WriteString("None.\n")
+			continue
+		}
+		writeMarkdownHeader(&b, []string{"Workload", "Containers", "CPU requested → recommended", "Memory requested → recommended"})
+		for _, w := range list.workloads {
+			fmt.Fprintf(&b, "| %s | %d | %s → %s | %s → %s |\n", markdownEscape(workloadName(w)), w.Containers,
+				formatCores(w.CPURequested), formatCores(w.CPURecommended),
+				formatGiB(w.MemoryRequested), formatGiB(w.MemoryRecommended))
+		}
+	}
+
+	fmt.Fprintf(&b, "\n## Changed recommendations (%d)\n\n", len(c.Changed))
+	if len(c.Changed) == 0 {
+		b.WriteString("None.\n")
+	} else {
+		writeMarkdownHeader(&b, []string{"Workload", "Container", "Resource", "From", "To", "Delta"})
+		for _, ch := range c.Changed {
+			fmt.Fprintf(&b, "| %s | %s | %s %s | %s | %s | %s |\n", markdownEscape(changeName(ch)), markdownEscape(ch.Container),
+				ch.Resource, ch.Field, dashIfEmpty(ch.From), dashIfEmpty(ch.To), formatChangeDelta(ch))
+		}
+	}
+
+	fmt.Fprintf(&b, "\n## Applied changes (%d)\n\n", len(c.Applied))
+	if len(c.Applied) == 0 {
+		b.WriteString("None.\n")
+	} else {
+		b.WriteString("Workloads whose requests or limits changed between the runs. Waste is the request above the recommendation.\n\n")
+		header := []string{"Workload", "CPU requested", "Memory requested", "CPU waste", "Memory waste", "Waste reduced"}
+		if c.Cost != nil {
+			header = append(header, fmt.Sprintf("Monthly cost (%s)", c.Cost.Currency))
+		}
+		writeMarkdownHeader(&b, header)
+		for _, a := range c.Applied {
+			cells := []string{markdownEscape(appliedName(a)),
+				fmt.Sprintf("%s → %s", formatCores(a.Before.CPURequested), formatCores(a.After.CPURequested)),
+				fmt.Sprintf("%s → %s", formatGiB(a.Before.MemoryRequested), formatGiB(a.After.MemoryRequested)),
+				fmt.Sprintf("%s → %s", formatCores(a.CPUWasteBefore), formatCores(a.CPUWasteAfter)),
+				fmt.Sprintf("%s → %s", formatGiB(a.MemoryWasteBefore), formatGiB(a.MemoryWasteAfter)),
+				yesNo(a.ReducedWaste)}
+			if c.Cost != nil {
+				cells = append(cells, fmt.Sprintf("%s → %s", formatMoney(a.Before.CurrentCost), formatMoney(a.After.CurrentCost)))
+			}
+			fmt.Fprintf(&b, "| %s |\n", strings.Join(cells, " | "))
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeMarkdownHeader(b *strings.Builder, header []string) {
+	fmt.Fprintf(b, "| %s |\n|%s\n", strings.Join(header, " | "), strings.Repeat("---|", len(header)))
+}
+
+func writeViolations(b *strings.Builder, violations []string) {
+	if len(violations) == 0 {
+		return
+	}
+	b.WriteString("\n**Policy violations**\n\n")
+	for _, v := range violations {
+		fmt.Fprintf(b, "- %s\n", markdownEscape(v))
+	}
+}
+
+// markdownChange renders "current → recommended", or a single value when
+// nothing changes.
+func markdownChange(current, recommended v1.ResourceList, name v1.ResourceName) string {
+	cur := dashIfEmpty(quantityString(current, name))
+	rec := dashIfEmpty(quantityString(recommended, name))
+	if cur == rec {
+		return cur
+	}
+	return fmt.Sprintf("%s → **%s**", cur, rec)
+}
+
+// markdownCost renders the current and recommended monthly cost of a
+// container.
+func markdownCost(c *models.Cost) string {
+	if c == nil {
+		return "-"
+	}
+	if c.Current == c.Recommended {
+		return formatMoney(c.Current)
+	}
+	return fmt.Sprintf("%s → **%s**", formatMoney(c.Current), formatMoney(c.Recommended))
+}
+
+func dashIfEmpty(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func markdownEscape(s string) string {
+	return strings.ReplaceAll(s, "|", `\|`)
+}
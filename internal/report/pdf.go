@@ -0,0 +1,381 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/jung-kurt/gofpdf"
+	"github.com/tabed23/k8s-resource-tuner/internal/models"
+	v1 "k8s.io/api/core/v1"
+)
+
+// Fill colors of the recommended values: amber when the workload is over
+// provisioned, red when it is under provisioned. Green marks the changes
+// that reduced waste in a comparison of two runs.
+var (
+	overProvisionedFill  = [3]int{255, 224, 178}
+	underProvisionedFill = [3]int{255, 190, 190}
+	reducedWasteFill     = [3]int{200, 235, 200}
+)
+
+var pdfColumns = []struct {
+	title string
+	width float64
+}{
+	{"Container", 34}, {"Resource", 16},
+	{"Cur. request", 22}, {"Cur. limit", 22},
+	{"Rec. request", 22}, {"Rec. limit", 22},
+	{"Request delta", 26}, {"Limit delta", 26},
+}
+
+// pdfLegend explains the colors of the resource tables.
+func pdfLegend(pdf *gofpdf.Fpdf) {
+	pdf.SetFont("Arial", "", 9)
+	for _, l := range []struct {
+		color [3]int
+		text  string
+	}{
+		{overProvisionedFill, fmt.Sprintf("Over-provisioned: recommended more than %d%% below current", provisioningPercent)},
+		{underProvisionedFill, fmt.Sprintf("Under-provisioned: recommended more than %d%% above current", provisioningPercent)},
+	} {
+		pdf.SetFillColor(l.color[0], l.color[1], l.color[2])
+		pdf.CellFormat(6, 4, "", "1", 0, "", true, 0, "")
+		pdf.CellFormat(120, 4, " "+l.text, "", 1, "L", false, 0, "")
+		pdf.Ln(1)
+	}
+}
+
+// pdfResourceTable lists the current and recommended CPU and memory of
+// every container of the entry, one row per container and resource.
+func pdfResourceTable(pdf *gofpdf.Fpdf, entry models.ReportEntry) {
+	if len(entry.Recommendation) == 0 {
+		return
+	}
+	widths := make([]float64, len(pdfColumns))
+	titles := make([]string, len(pdfColumns))
+	for i, c := range pdfColumns {
+		widths[i], titles[i] = c.width, c.title
+	}
+	pdfTableHeader(pdf, widths, titles...)
+
+	pdf.SetFont("Arial", "", 8)
+	for _, rec := range entry.Recommendation {
+		current := containerResources(entry.Workload, rec.ContainerName)
+		for _, name := range []v1.ResourceName{v1.ResourceCPU, v1.ResourceMemory} {
+			request := newChange(entry, rec.ContainerName, name, "request", current.Request, rec.RecommendedRequest.Request)
+			limit := newChange(entry, rec.ContainerName, name, "limit", current.Limits, rec.RecommendedLimit.Limits)
+			cells := []struct {
+				text   string
+				change *Change
+			}{
+				{fitText(pdf, rec.ContainerName, pdfColumns[0].width), nil},
+				{string(name), nil},
+				{dashIfEmpty(request.Current), nil},
+				{dashIfEmpty(limit.Current), nil},
+				{dashIfEmpty(request.Recommended), &request},
+				{dashIfEmpty(limit.Recommended), &limit},
+				{pdfDelta(request), &request},
+				{pdfDelta(limit), &limit},
+			}
+			for i, c := range cells {
+				fill := false
+				if c.change != nil {
+					if color, ok := provisioningColor(*c.change); ok {
+						pdf.SetFillColor(color[0], color[1], color[2])
+						fill = true
+					}
+				}
+				align := "R"
+				if i < 2 {
+					align = "L"
+				}
+				pdf.CellFormat(pdfColumns[i].width, 5, c.text, "1", 0, align, fill, 0, "")
+			}
+			pdf.Ln(-1)
+		}
+	}
+	pdf.Ln(2)
+}
+
+// provisioningColor returns the fill color of a recommended value, if it is
+// far enough from the current one to stand out.
+func provisioningColor(c Change) ([3]int, bool) {
+	switch classify(c) {
+	case overProvisioned:
+		return overProvisionedFill, true
+	case underProvisioned:
+		return underProvisionedFill, true
+	}
+	return [3]int{}, false
+}
+
+// pdfDelta renders the change as "+250m (+50%)" for CPU or "-128Mi (-25%)"
+// for memory.
+func pdfDelta(c Change) string {
+	switch {
+	case c.Current == "" && c.Recommended == "":
+		return "-"
+	case c.Current == "":
+		return "added"
+	case c.Recommended == "":
+		return "removed"
+	}
+	var abs string
+	if c.Resource == v1.ResourceCPU {
+		abs = fmt.Sprintf("%+dm", int64(math.Round(c.Delta*1000)))
+	} else {
+		abs = fmt.Sprintf("%+dMi", int64(math.Round(c.Delta/mebibyte)))
+	}
+	return fmt.Sprintf("%s (%+.0f%%)", abs, c.DeltaPercent)
+}
+
+// fitText shortens s with an ellipsis until it fits a cell of the given
+// width.
+func fitText(pdf *gofpdf.Fpdf, s string, width float64) string {
+	const padding = 2
+	if pdf.GetStringWidth(s) <= width-padding {
+		return s
+	}
+	runes := []rune(s)
+	for len(runes) > 0 && pdf.GetStringWidth(string(runes)+"...") > width-padding {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "..."
+}
+
+// pdfSummary renders the executive summary: the totals of the report, of
+// every cluster and namespace, and the rankings of the most wasteful and
+// most at-risk workloads.
+func pdfSummary(pdf *gofpdf.Fpdf, s models.Summary) {
+	pdf.SetFont("Arial", "B", 14)
+	pdf.Cell(200, 10, "Executive Summary:")
+	pdf.Ln(10)
+	pdf.SetFont("Arial", "", 11)
+	pdf.Cell(200, 6, fmt.Sprintf("%d containers: %d over-provisioned, %d under-provisioned",
+		s.Total.Containers, s.Total.OverProvisioned, s.Total.UnderProvisioned))
+	pdf.Ln(6)
+	pdf.Cell(200, 6, fmt.Sprintf("CPU: %s requested, %s recommended | Memory: %s requested, %s recommended",
+		formatCores(s.Total.CPURequested), formatCores(s.Total.CPURecommended),
+		formatGiB(s.Total.MemoryRequested), formatGiB(s.Total.MemoryRecommended)))
+	pdf.Ln(9)
+
+	widths := []float64{50, 18, 16, 16, 22, 22, 23, 23}
+	pdfTableHeader(pdf, widths, "Scope", "Containers", "Over", "Under", "CPU req.", "CPU rec.", "Memory req.", "Memory rec.")
+	row := func(scope string, t models.Totals) {
+		pdfTableRow(pdf, widths, fitText(pdf, scope, widths[0]),
+			fmt.Sprint(t.Containers), fmt.Sprint(t.OverProvisioned), fmt.Sprint(t.UnderProvisioned),
+			formatCores(t.CPURequested), formatCores(t.CPURecommended),
+			formatGiB(t.MemoryRequested), formatGiB(t.MemoryRecommended))
+	}
+	pdf.SetFont("Arial", "B", 8)
+	row("Total", s.Total)
+	pdf.SetFont("Arial", "", 8)
+	for _, c := range s.Clusters {
+		row(scopeName(c), c.Totals)
+	}
+	for _, ns := range s.Namespaces {
+		row(scopeName(ns), ns.Totals)
+	}
+	pdf.Ln(4)
+
+	if s.Currency != "" {
+		pdf.SetFont("Arial", "B", 11)
+		pdf.Cell(200, 8, fmt.Sprintf("Monthly cost (%s)", s.Currency))
+		pdf.Ln(8)
+		widths := []float64{70, 40, 40, 40}
+		pdfTableHeader(pdf, widths, "Scope", "Current", "Recommended", "Savings")
+		row := func(scope string, t models.Totals) {
+			pdfTableRow(pdf, widths, fitText(pdf, scope, widths[0]),
+				formatMoney(t.CurrentCost), formatMoney(t.RecommendedCost), formatMoney(t.Savings))
+		}
+		pdf.SetFont("Arial", "B", 8)
+		row("Total", s.Total)
+		pdf.SetFont("Arial", "", 8)
+		for _, c := range s.Clusters {
+			row(scopeName(c), c.Totals)
+		}
+		for _, ns := range s.Namespaces {
+			row(scopeName(ns), ns.Totals)
+		}
+		for _, t := range s.Teams {
+			row(teamName(t), t.Totals)
+		}
+		pdf.Ln(4)
+	}
+
+	for _, ranking := range []struct {
+		title     string
+		workloads []models.WorkloadTotals
+	}{{"Most wasteful workloads", s.TopWasteful}, {"Most at-risk workloads", s.TopAtRisk}} {
+		if len(ranking.workloads) == 0 {
+			continue
+		}
+		pdf.SetFont("Arial", "B", 11)
+		pdf.Cell(200, 8, ranking.title)
+		pdf.Ln(8)
+		widths := []float64{8, 86, 24, 24, 24, 24}
+		titles := []string{"#", "Workload", "CPU req.", "CPU rec.", "Memory req.", "Memory rec."}
+		if s.Currency != "" {
+			widths = []float64{8, 70, 22, 22, 22, 22, 24}
+			titles = append(titles, fmt.Sprintf("Savings (%s)", s.Currency))
+		}
+		pdfTableHeader(pdf, widths, titles...)
+		pdf.SetFont("Arial", "", 8)
+		for i, w := range ranking.workloads {
+			cells := []string{fmt.Sprint(i + 1), fitText(pdf, workloadName(w), widths[1]),
+				formatCores(w.CPURequested), formatCores(w.CPURecommended),
+				formatGiB(w.MemoryRequested), formatGiB(w.MemoryRecommended)}
+			if s.Currency != "" {
+				cells = append(cells, formatMoney(w.Savings))
+			}
+			pdfTableRow(pdf, widths, cells...)
+		}
+		pdf.Ln(4)
+	}
+}
+
+func pdfTableHeader(pdf *gofpdf.Fpdf, widths []float64, titles ...string) {
+	pdf.SetFont("Arial", "B", 8)
+	pdf.SetFillColor(230, 230, 230)
+	for i, t := range titles {
+		pdf.CellFormat(widths[i], 5, t, "1", 0, "C", true, 0, "")
+	}
+	pdf.Ln(-1)
+}
+
+// pdfTableRow writes a row of cells, the first two aligned left and the
+// others, numbers, aligned right.
+func pdfTableRow(pdf *gofpdf.Fpdf, widths []float64, cells ...string) {
+	for i, c := range cells {
+		align := "R"
+		if i < 2 {
+			align = "L"
+		}
+		pdf.CellFormat(widths[i], 5, c, "1", 0, align, false, 0, "")
+	}
+	pdf.Ln(-1)
+}
+
+// ComparisonPDF writes the comparison of two runs as a PDF document.
+func ComparisonPDF(w io.Writer, c RunComparison) error {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetTitle("Kubernetes Resource Recommendation Changes", true)
+	pdf.AddPage()
+
+	pdf.SetFont("Arial", "B", 16)
+	pdf.Cell(200, 10, "Kubernetes Resource Recommendation Changes")
+	pdf.Ln(12)
+	pdf.SetFont("Arial", "", 11)
+	for _, line := range []string{
+		"From: " + runLabel(c.From),
+		"To: " + runLabel(c.To),
+		fmt.Sprintf("Threshold: %g%%", c.ThresholdPercent),
+	} {
+		pdf.Cell(200, 6, line)
+		pdf.Ln(6)
+	}
+	pdf.Ln(4)
+
+	section := func(title string, count int) bool {
+		ensureSpace(pdf, 20)
+		pdf.SetFont("Arial", "B", 12)
+		pdf.Cell(200, 8, fmt.Sprintf("%s (%d)", title, count))
+		pdf.Ln(8)
+		if count == 0 {
+			pdf.SetFont("Arial", "", 9)
+			pdf.Cell(200, 5, "None.")
+			pdf.Ln(8)
+			return false
+		}
+		return true
+	}
+
+	if c.Cost != nil {
+		pdf.SetFont("Arial", "B", 12)
+		pdf.Cell(200, 8, fmt.Sprintf("Monthly cost (%s)", c.Cost.Currency))
+		pdf.Ln(8)
+		widths := []float64{40, 35, 35, 35}
+		pdfTableHeader(pdf, widths, "", "From", "To", "Delta")
+		pdf.SetFont("Arial", "", 8)
+		for _, r := range []struct {
+			name     string
+			from, to float64
+		}{
+			{"Current", c.Cost.FromCurrent, c.Cost.ToCurrent},
+			{"Recommended", c.Cost.FromRecommended, c.Cost.ToRecommended},
+			{"Savings", c.Cost.FromSavings, c.Cost.ToSavings},
+		} {
+			pdfTableRow(pdf, widths, r.name, formatMoney(r.from), formatMoney(r.to), fmt.Sprintf("%+.2f", r.to-r.from))
+		}
+		pdf.Ln(6)
+	}
+
+	for _, list := range []struct {
+		title     string
+		workloads []models.WorkloadTotals
+	}{{"Appeared workloads", c.Appeared}, {"Disappeared workloads", c.Disappeared}} {
+		if !section(list.title, len(list.workloads)) {
+			continue
+		}
+		widths := []float64{86, 20, 24, 24, 18, 18}
+		pdfTableHeader(pdf, widths, "Workload", "Containers", "CPU req.", "CPU rec.", "Mem. req.", "Mem. rec.")
+		pdf.SetFont("Arial", "", 8)
+		for _, wl := range list.workloads {
+			pdfTableRow(pdf, widths, fitText(pdf, workloadName(wl), widths[0]), fmt.Sprint(wl.Containers),
+				formatCores(wl.CPURequested), formatCores(wl.CPURecommended),
+				formatGiB(wl.MemoryRequested), formatGiB(wl.MemoryRecommended))
+		}
+		pdf.Ln(6)
+	}
+
+	if section("Changed recommendations", len(c.Changed)) {
+		widths := []float64{70, 30, 26, 22, 22, 20}
+		pdfTableHeader(pdf, widths, "Workload", "Container", "Resource", "From", "To", "Delta")
+		pdf.SetFont("Arial", "", 8)
+		for _, ch := range c.Changed {
+			pdfTableRow(pdf, widths, fitText(pdf, changeName(ch), widths[0]), fitText(pdf, ch.Container, widths[1]),
+				fmt.Sprintf("%s %s", ch.Resource, ch.Field), dashIfEmpty(ch.From), dashIfEmpty(ch.To), formatChangeDelta(ch))
+		}
+		pdf.Ln(6)
+	}
+
+	if section("Applied changes", len(c.Applied)) {
+		pdf.SetFont("Arial", "", 9)
+		pdf.Cell(200, 5, "Workloads whose requests or limits changed between the runs. Waste is the request above the recommendation.")
+		pdf.Ln(7)
+		widths := []float64{58, 33, 33, 33, 33}
+		titles := []string{"Workload", "CPU requested", "Memory requested", "CPU waste", "Memory waste"}
+		if c.Cost != nil {
+			widths = []float64{50, 28, 28, 28, 28, 28}
+			titles = append(titles, fmt.Sprintf("Cost (%s)", c.Cost.Currency))
+		}
+		pdfTableHeader(pdf, widths, titles...)
+		pdf.SetFont("Arial", "", 8)
+		for _, a := range c.Applied {
+			cells := []string{fitText(pdf, appliedName(a), widths[0]),
+				fmt.Sprintf("%.2f -> %.2f", a.Before.CPURequested, a.After.CPURequested),
+				fmt.Sprintf("%.2f -> %.2f", a.Before.MemoryRequested, a.After.MemoryRequested),
+				fmt.Sprintf("%.2f -> %.2f", a.CPUWasteBefore, a.CPUWasteAfter),
+				fmt.Sprintf("%.2f -> %.2f", a.MemoryWasteBefore, a.MemoryWasteAfter)}
+			if c.Cost != nil {
+				cells = append(cells, fmt.Sprintf("%s -> %s", formatMoney(a.Before.CurrentCost), formatMoney(a.After.CurrentCost)))
+			}
+			pdf.SetFillColor(reducedWasteFill[0], reducedWasteFill[1], reducedWasteFill[2])
+			for i, cell := range cells {
+				align := "R"
+				if i == 0 {
+					align = "L"
+				}
+				pdf.CellFormat(widths[i], 5, cell, "1", 0, align, a.ReducedWaste, 0, "")
+			}
+			pdf.Ln(-1)
+		}
+		pdf.SetFont("Arial", "", 8)
+		pdf.Ln(1)
+		pdf.Cell(200, 5, "CPU in cores and memory in GiB, over all replicas. Green rows reduced their waste.")
+		pdf.Ln(6)
+	}
+
+	return pdf.Output(w)
+}
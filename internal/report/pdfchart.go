@@ -0,0 +1,244 @@
+package report
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/jung-kurt/gofpdf"
+	"github.com/tabed23/k8s-resource-tuner/internal/models"
+	v1 "k8s.io/api/core/v1"
+)
+
+// maxChartPoints bounds the points of a PDF line chart. Longer series are
+// reduced to the maximum of each time bucket, which keeps the peaks visible
+// and the file small.
+const maxChartPoints = 240
+
+const gibibyte = 1024 * mebibyte
+
+// ensureSpace starts a new page unless height millimeters fit above the
+// bottom margin.
+func ensureSpace(pdf *gofpdf.Fpdf, height float64) {
+	_, pageH := pdf.GetPageSize()
+	_, _, _, bottom := pdf.GetMargins()
+	if _, margin := pdf.GetAutoPageBreak(); margin > bottom {
+		bottom = margin
+	}
+	if pdf.GetY()+height > pageH-bottom {
+		pdf.AddPage()
+	}
+}
+
+// pdfUsageCharts draws the CPU and memory charts of a container side by
+// side, followed by the legend of the markers.
+func pdfUsageCharts(pdf *gofpdf.Fpdf, window timeWindow, w models.WorkLoad, rec models.Recommendation) {
+	const width, height, gap = 92.0, 45.0, 6.0
+	ensureSpace(pdf, height+10)
+	current := containerResources(w, rec.ContainerName)
+	var cpuSeries, memSeries []models.Point
+	if u := rec.UsageStats; u != nil {
+		cpuSeries, memSeries = u.CPUSeries, u.MemSeries
+	}
+	left, _, _, _ := pdf.GetMargins()
+	y := pdf.GetY()
+	pdfLineChart(pdf, left, y, width, height, "CPU (cores)", chartSegments(cpuSeries, 1, window, maxChartPoints),
+		window, markers(current, rec, v1.ResourceCPU, 1))
+	pdfLineChart(pdf, left+width+gap, y, width, height, "Memory (MiB)", chartSegments(memSeries, mebibyte, window, maxChartPoints),
+		window, markers(current, rec, v1.ResourceMemory, mebibyte))
+	pdf.SetXY(left, y+height+1)
+
+	pdf.SetFont("Arial", "", 7)
+	seen := map[string]bool{}
+	for _, m := range append(markers(current, rec, v1.ResourceCPU, 1), markers(current, rec, v1.ResourceMemory, mebibyte)...) {
+		if seen[m.label] {
+			continue
+		}
+		seen[m.label] = true
+		x, ly := pdf.GetX(), pdf.GetY()+2
+		setMarkerStyle(pdf, m)
+		pdf.Line(x, ly, x+6, ly)
+		resetLineStyle(pdf)
+		pdf.SetX(x + 7)
+		pdf.CellFormat(pdf.GetStringWidth(m.label)+4, 4, m.label, "", 0, "L", false, 0, "")
+	}
+	pdf.Ln(6)
+}
+
+// pdfLineChart draws the usage over the lookback window, a line per run of
+// consecutive steps, with the markers as horizontal lines, in the box at x, y.
+func pdfLineChart(pdf *gofpdf.Fpdf, x, y, width, height float64, title string, segments [][]chartPoint, window timeWindow, ms []marker) {
+	const left, top, bottom = 12.0, 5.0, 5.0
+	plotX, plotY := x+left, y+top
+	plotW, plotH := width-left, height-top-bottom
+
+	max := segmentsMax(segments, ms)
+	px := func(f float64) float64 { return plotX + f*plotW }
+	py := func(v float64) float64 { return plotY + plotH - v/max*plotH }
+
+	pdf.SetFont("Arial", "B", 8)
+	pdf.Text(plotX, y+3, title)
+	pdf.SetFont("Arial", "", 6)
+	pdf.SetDrawColor(136, 136, 136)
+	pdf.SetLineWidth(0.2)
+	pdf.Line(plotX, plotY, plotX, plotY+plotH)
+	pdf.Line(plotX, plotY+plotH, plotX+plotW, plotY+plotH)
+	for _, v := range []float64{0, max / 2, max} {
+		tick := formatTick(v)
+		pdf.Text(plotX-1-pdf.GetStringWidth(tick), py(v)+1, tick)
+	}
+	if !window.start.IsZero() {
+		from, to := formatChartTime(window.start), formatChartTime(window.end)
+		pdf.Text(plotX, plotY+plotH+3, from)
+		pdf.Text(plotX+plotW-pdf.GetStringWidth(to), plotY+plotH+3, to)
+	}
+
+	pdf.SetDrawColor(85, 85, 85)
+	pdf.SetLineWidth(0.25)
+	for _, seg := range segments {
+		if len(seg) == 1 {
+			pdf.Line(px(seg[0].x)-0.3, py(seg[0].v), px(seg[0].x)+0.3, py(seg[0].v))
+		}
+		for i := 1; i < len(seg); i++ {
+			pdf.Line(px(seg[i-1].x), py(seg[i-1].v), px(seg[i].x), py(seg[i].v))
+		}
+	}
+	if len(segments) == 0 {
+		msg := "no usage samples"
+		pdf.Text(plotX+(plotW-pdf.GetStringWidth(msg))/2, plotY+plotH/2, msg)
+	}
+
+	for _, m := range ms {
+		setMarkerStyle(pdf, m)
+		pdf.Line(plotX, py(m.value), plotX+plotW, py(m.value))
+	}
+	resetLineStyle(pdf)
+}
+
+func setMarkerStyle(pdf *gofpdf.Fpdf, m marker) {
+	var r, g, b int
+	fmt.Sscanf(m.color, "#%02x%02x%02x", &r, &g, &b)
+	pdf.SetDrawColor(r, g, b)
+	pdf.SetLineWidth(0.35)
+	if m.dashed {
+		pdf.SetDashPattern([]float64{1.5, 1}, 0)
+	}
+}
+
+func resetLineStyle(pdf *gofpdf.Fpdf) {
+	pdf.SetDashPattern([]float64{}, 0)
+	pdf.SetDrawColor(0, 0, 0)
+	pdf.SetLineWidth(0.2)
+}
+
+// namespaceUsage is the total requested and used CPU (cores) and memory
+// (GiB) of the long-running containers of a namespace, over all replicas.
+type namespaceUsage struct {
+	Name                  string
+	CPURequested, CPUUsed float64
+	MemRequested, MemUsed float64
+}
+
+// namespaceTotals sums the requests and the average usage of every app and
+// sidecar container by namespace, times the number of pods of its workload.
+// Init containers are left out since they do not run next to the others.
+func namespaceTotals(entries []models.ReportEntry) []namespaceUsage {
+	byName := map[string]*namespaceUsage{}
+	var names []string
+	for _, e := range entries {
+		name := e.Workload.Namespace
+		if e.Cluster != "" {
+			name = e.Cluster + "/" + name
+		}
+		t, ok := byName[name]
+		if !ok {
+			t = &namespaceUsage{Name: name}
+			byName[name] = t
+			names = append(names, name)
+		}
+		n := e.ReplicaCount()
+		for _, rec := range e.Recommendation {
+			if containerRole(e.Workload, rec.ContainerName) == models.RoleInit {
+				continue
+			}
+			current := containerResources(e.Workload, rec.ContainerName)
+			if q, ok := current.Request[v1.ResourceCPU]; ok {
+				t.CPURequested += q.AsApproximateFloat64() * n
+			}
+			if q, ok := current.Request[v1.ResourceMemory]; ok {
+				t.MemRequested += q.AsApproximateFloat64() / gibibyte * n
+			}
+			if u := rec.UsageStats; u != nil {
+				t.CPUUsed += u.CPUAvg * n
+				t.MemUsed += u.MemAvg / gibibyte * n
+			}
+		}
+	}
+	sort.Strings(names)
+	totals := make([]namespaceUsage, 0, len(names))
+	for _, name := range names {
+		totals = append(totals, *byName[name])
+	}
+	return totals
+}
+
+// pdfNamespaceChart draws horizontal bars of the requested and used CPU
+// and memory of every namespace.
+func pdfNamespaceChart(pdf *gofpdf.Fpdf, totals []namespaceUsage) {
+	if len(totals) == 0 {
+		return
+	}
+	requested, used := [3]int{31, 119, 180}, [3]int{44, 160, 44}
+	for _, chart := range []struct {
+		title  string
+		values func(namespaceUsage) (float64, float64)
+	}{
+		{"CPU (cores)", func(t namespaceUsage) (float64, float64) { return t.CPURequested, t.CPUUsed }},
+		{"Memory (GiB)", func(t namespaceUsage) (float64, float64) { return t.MemRequested, t.MemUsed }},
+	} {
+		const labelW, barW, barH = 45.0, 120.0, 3.0
+		max := 0.0
+		for _, t := range totals {
+			r, u := chart.values(t)
+			max = math.Max(max, math.Max(r, u))
+		}
+		if max == 0 {
+			max = 1
+		}
+		ensureSpace(pdf, 10+float64(len(totals))*(2*barH+2))
+		pdf.SetFont("Arial", "B", 10)
+		pdf.Cell(200, 6, chart.title)
+		pdf.Ln(6)
+		left, _, _, _ := pdf.GetMargins()
+		pdf.SetFont("Arial", "", 7)
+		for _, t := range totals {
+			ensureSpace(pdf, 2*barH+2)
+			y := pdf.GetY()
+			pdf.SetXY(left, y)
+			pdf.CellFormat(labelW, 2*barH, fitText(pdf, t.Name, labelW), "", 0, "L", false, 0, "")
+			r, u := chart.values(t)
+			for i, bar := range []struct {
+				value float64
+				color [3]int
+			}{{r, requested}, {u, used}} {
+				by := y + float64(i)*barH
+				pdf.SetFillColor(bar.color[0], bar.color[1], bar.color[2])
+				pdf.Rect(left+labelW, by, math.Max(bar.value/max*barW, 0.2), barH-0.4, "F")
+				pdf.Text(left+labelW+bar.value/max*barW+1, by+barH-0.9, formatTick(bar.value))
+			}
+			pdf.SetXY(left, y+2*barH+2)
+		}
+		pdf.Ln(2)
+	}
+
+	pdf.SetFont("Arial", "", 8)
+	for _, l := range []struct {
+		color [3]int
+		text  string
+	}{{requested, "requested"}, {used, "used (average)"}} {
+		pdf.SetFillColor(l.color[0], l.color[1], l.color[2])
+		pdf.CellFormat(6, 4, "", "", 0, "", true, 0, "")
+		pdf.CellFormat(30, 4, " "+l.text, "", 0, "L", false, 0, "")
+	}
+	pdf.Ln(8)
+}
@@ -0,0 +1,106 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/tabed23/k8s-resource-tuner/internal/models"
+)
+
+// Renderer writes a report in one output format.
+type Renderer interface {
+	// Extension is the file name extension of the format.
+	Extension() string
+	// Render writes the report. namespace names the scanned namespace, or
+	// is ALL_NAMESPACES for a run over several of them.
+	Render(w io.Writer, reportData models.Report, namespace string) error
+}
+
+// summaryRenderer is a renderer that writes the summary of the report to a
+// file of its own, such as CSV whose rows are all containers.
+type summaryRenderer interface {
+	RenderSummary(w io.Writer, reportData models.Report) error
+}
+
+var renderers = map[string]Renderer{
+	"pdf":      pdfRenderer{},
+	"json":     jsonRenderer{},
+	"yaml":     yamlRenderer{},
+	"csv":      csvRenderer{},
+	"markdown": markdownRenderer{},
+	"html":     htmlRenderer{},
+}
+
+// Formats returns the names of the known output formats.
+func Formats() []string {
+	names := make([]string, 0, len(renderers))
+	for name := range renderers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RendererFor returns the renderer of a format.
+func RendererFor(format string) (Renderer, error) {
+	r, ok := renderers[format]
+	if !ok {
+		return nil, fmt.Errorf("unknown report format %q (known: %s)", format, strings.Join(Formats(), ", "))
+	}
+	return r, nil
+}
+
+// WriteReport renders the report to k8s_resource_report_<namespace>_<time>
+// in the working directory and returns the file name. Formats with a
+// separate summary write it next to the report, with a _summary suffix.
+func WriteReport(r Renderer, reportData models.Report, namespace string) (string, error) {
+	reportFilename := fmt.Sprintf("k8s_resource_report_%s_%s.%s",
+		namespace,
+		reportData.Timestamp.Format("20060102_150405"),
+		r.Extension())
+
+	f, err := os.Create(reportFilename)
+	if err != nil {
+		return "", fmt.Errorf("failed to save report: %v", err)
+	}
+	if err := r.Render(f, reportData, namespace); err != nil {
+		f.Close()
+		return "", fmt.Errorf("failed to render %s report: %v", r.Extension(), err)
+	}
+	if err := f.Close(); err != nil {
+		return "", fmt.Errorf("failed to save report: %v", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "Report saved as: %s\n", reportFilename)
+
+	if sr, ok := r.(summaryRenderer); ok && reportData.Summary.Total.Containers > 0 {
+		summaryFilename := strings.TrimSuffix(reportFilename, "."+r.Extension()) + "_summary." + r.Extension()
+		f, err := os.Create(summaryFilename)
+		if err != nil {
+			return "", fmt.Errorf("failed to save summary: %v", err)
+		}
+		if err := sr.RenderSummary(f, reportData); err != nil {
+			f.Close()
+			return "", fmt.Errorf("failed to render %s summary: %v", r.Extension(), err)
+		}
+		if err := f.Close(); err != nil {
+			return "", fmt.Errorf("failed to save summary: %v", err)
+		}
+		fmt.Fprintf(os.Stderr, "Summary saved as: %s\n", summaryFilename)
+	}
+	return reportFilename, nil
+}
+
+// containerResources returns the current resources of a container of the
+// workload.
+func containerResources(w models.WorkLoad, container string) models.ResourceConfig {
+	for _, c := range w.Containers {
+		if c.Name == container {
+			return c.Resources
+		}
+	}
+	return models.ResourceConfig{}
+}
@@ -2,6 +2,10 @@ package report
 
 import (
 	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/jung-kurt/gofpdf"
@@ -11,57 +15,112 @@ import (
 	"github.com/tabed23/k8s-resource-tuner/internal/prometheus"
 	"github.com/tabed23/k8s-resource-tuner/internal/recommendation"
 	"github.com/tabed23/k8s-resource-tuner/internal/stats"
+	v1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/kubernetes"
 )
 
-func GenrateReport(clientset *kubernetes.Clientset, prom *prometheus.PromClient, namespace string) (models.Report, error) {
+// Options scope and tune a report run.
+type Options struct {
+	// Selector is a label selector workloads must match, e.g.
+	// "app.kubernetes.io/part-of=checkout".
+	Selector string
+	// AnnotationSelector selects workloads by annotations with the same
+	// syntax.
+	AnnotationSelector string
+	// Cluster tags every entry when several clusters are scanned.
+	Cluster string
+	// Lookback is the usage window and Step the Prometheus query
+	// resolution, 9h and 60s when unset.
+	Lookback time.Duration
+	Step     time.Duration
+	// Policies selects the policy of each workload, the built-in presets
+	// with recommendation.DefaultPolicy when unset.
+	Policies recommendation.PolicySet
+}
+
+func GenrateReport(clientset *kubernetes.Clientset, prom *prometheus.PromClient, namespace string, opts Options) (models.Report, error) {
 
-	worloads, err := k8s.ListDeployments(clientset, namespace)
+	worloads, err := k8s.ListWorkloads(clientset, namespace, k8s.WorkloadFilter{LabelSelector: opts.Selector, AnnotationSelector: opts.AnnotationSelector})
 	if err != nil {
-		panic(err)
+		return models.Report{}, fmt.Errorf("failed to list workloads in %s: %v", namespace, err)
 	}
-	for _, w := range worloads {
-		helper.PrettyPrintWorkload(w)
+	owners, err := k8s.NewOwnerIndex(clientset, namespace)
+	if err != nil {
+		return models.Report{}, fmt.Errorf("failed to resolve pod owners in %s: %v", namespace, err)
+	}
+	for i := range worloads {
+		worloads[i].Pods = owners.Pods(worloads[i])
+		worloads[i].Nodes = owners.Nodes(worloads[i])
+		worloads[i].PodPattern = owners.PodPattern(worloads[i])
 	}
 	if len(worloads) == 0 {
-		fmt.Println("No deployments found in namespace anubis")
+		fmt.Fprintf(os.Stderr, "No workloads found in namespace %s\n", namespace)
 	}
 
 	var reportEntries []models.ReportEntry
-	var summary string
+
+	lookback, stepSize := opts.Lookback, opts.Step
+	if lookback == 0 {
+		lookback = 9 * time.Hour
+	}
+	if stepSize == 0 {
+		stepSize = 60 * time.Second
+	}
+	policies := opts.Policies
+	if policies.Policies == nil {
+		policies = recommendation.DefaultPolicySet()
+	}
 
 	end := time.Now()
-	start := end.Add(-9 * time.Hour)
-	step := "60"
+	start := end.Add(-lookback)
+	step := fmt.Sprintf("%.0f", stepSize.Seconds())
 
 	for _, w := range worloads {
+		if w.Ignored() {
+			fmt.Fprintf(os.Stderr, "Skipping %s %s/%s: %s annotation is set\n", w.Kind, w.Namespace, w.Name, models.AnnotationIgnore)
+			continue
+		}
+		policy, err := policies.For(w)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v, using %s policy\n", err, policy.Name)
+		}
+		if w.PodPattern == "" {
+			fmt.Fprintf(os.Stderr, "No pods found for %s %s/%s, skipping\n", w.Kind, w.Namespace, w.Name)
+			continue
+		}
 		var statsList []models.UsageStats
 		var recommendations []models.Recommendation
 
 		for _, container := range w.Containers {
-			cpuVals, err := prom.QueryCpu(w.Namespace, w.Name, start, end, step)
+			cpuPoints, err := prom.QueryCpu(w.Namespace, w.PodPattern, container.Name, start, end, step)
 			if err != nil {
-				fmt.Printf("Error querying CPU for container %s: %v\n", container.Name, err)
+				fmt.Fprintf(os.Stderr, "Error querying CPU for container %s: %v\n", container.Name, err)
 				continue
 			}
-			memVals, err := prom.QueryMemory(w.Namespace, w.Name, start, end, step)
+			memPoints, err := prom.QueryMemory(w.Namespace, w.PodPattern, container.Name, start, end, step)
 			if err != nil {
-				fmt.Printf("Error querying Memory for container %s: %v\n", container.Name, err)
+				fmt.Fprintf(os.Stderr, "Error querying Memory for container %s: %v\n", container.Name, err)
 				continue
 			}
-			currentCpu, err := prom.QueryCurrentCpu(w.Namespace, w.Name)
+			cpuVals, memVals := stats.Values(cpuPoints), stats.Values(memPoints)
+			// Prometheus only returns samples while a series exists, so Job
+			// and CronJob pods are measured over their run windows and init
+			// containers until they complete, idle readings included.
+			currentCpu, err := prom.QueryCurrentCpu(w.Namespace, w.PodPattern, container.Name)
 			if err != nil {
-				fmt.Printf("Error querying current CPU for container %s: %v\n", container.Name, err)
+				fmt.Fprintf(os.Stderr, "Error querying current CPU for container %s: %v\n", container.Name, err)
 			}
-			currentMem, err := prom.QueryCurrentMemory(w.Namespace, w.Name)
+			currentMem, err := prom.QueryCurrentMemory(w.Namespace, w.PodPattern, container.Name)
 			if err != nil {
-				fmt.Printf("Error querying current Memory for container %s: %v\n", container.Name, err)
+				fmt.Fprintf(os.Stderr, "Error querying current Memory for container %s: %v\n", container.Name, err)
 			}
 
 			usageStats := models.UsageStats{
 				ContainerName: container.Name,
 				CPUSamples:    cpuVals,
 				MemSamples:    memVals,
+				CPUSeries:     stats.MaxPerStep(cpuPoints),
+				MemSeries:     stats.MaxPerStep(memPoints),
 				CPUAvg:        stats.Avg(cpuVals),
 				CPUP95:        stats.Percentile(cpuVals, 95),
 				CPUP99:        stats.Percentile(cpuVals, 99),
@@ -72,31 +131,88 @@ func GenrateReport(clientset *kubernetes.Clientset, prom *prometheus.PromClient,
 				CurrentMemory: currentMem,
 			}
 
-			rec := recommendation.RecommendFromStats(usageStats)
+			var rec models.Recommendation
+			switch {
+			case container.Role == models.RoleInit && len(cpuVals) == 0 && len(memVals) == 0:
+				rec = recommendation.KeepCurrent(container, "No usage observed, the init container did not run within the lookback window")
+			case len(cpuVals) == 0 || len(memVals) == 0:
+				// A workload scaled to zero or a gap in the metrics would
+				// otherwise be recommended the policy minimum.
+				rec = recommendation.KeepCurrent(container, "No CPU or memory usage observed within the lookback window, keeping the current resources")
+			default:
+				rec = recommendation.RecommendWithPolicy(usageStats, policy)
+			}
 			rec.UsageStats = &usageStats // Assign UsageStats to the Recommendation
+			rec.Violations = recommendation.CheckCompliance(container, policy)
 
 			statsList = append(statsList, usageStats)
 			recommendations = append(recommendations, rec)
 
 		}
-		reportEntries = append(reportEntries, models.ReportEntry{
-			Workload:       w,
-			Recommendation: recommendations,
-		})
+		currentPodRequest, _ := recommendation.EffectivePodRequest(w.Containers)
+		recommendedPodRequest, dominant := recommendation.EffectivePodRequest(
+			recommendation.WithRecommendedRequests(w.Containers, recommendations))
+		for i := range recommendations {
+			for res, name := range dominant {
+				if name == recommendations[i].ContainerName {
+					recommendations[i].Reason += fmt.Sprintf("; sets the effective pod %s request", res)
+				}
+			}
+		}
 
-		summary += fmt.Sprintf("Workload: %s\n", w.Name)
+		entry := models.ReportEntry{
+			Cluster:               opts.Cluster,
+			Workload:              w,
+			Stats:                 statsList,
+			Recommendation:        recommendations,
+			CurrentPodRequest:     currentPodRequest,
+			RecommendedPodRequest: recommendedPodRequest,
+		}
+		if w.IsBatch() {
+			entry.Replicas = averagePods(w, statsList, lookback.Seconds()/stepSize.Seconds())
+		}
+		reportEntries = append(reportEntries, entry)
 
 	}
 
 	return models.Report{
 		Timestamp: time.Now(),
+		Lookback:  lookback.String(),
 		Entries:   reportEntries,
-		Summary:   summary,
+		Summary:   Summarize(reportEntries),
 	}, nil
 
 }
 
+// averagePods returns the average number of pods of a batch workload running
+// over the lookback window of steps. Prometheus returns one sample per pod
+// and step, so the container with the most samples tells the pod time.
+func averagePods(w models.WorkLoad, statsList []models.UsageStats, steps float64) float64 {
+	if steps <= 0 {
+		return 0
+	}
+	samples := 0
+	for _, u := range statsList {
+		for _, c := range w.Containers {
+			if c.Name == u.ContainerName && c.Role != models.RoleInit {
+				samples = max(samples, len(u.CPUSamples), len(u.MemSamples))
+			}
+		}
+	}
+	return math.Round(float64(samples)/steps*1000) / 1000
+}
+
+// PDFReport writes the report as a PDF file and returns its name. It is kept
+// for existing callers; new code goes through RendererFor and WriteReport.
 func PDFReport(reportData models.Report, namespace string) (string, error) {
+	return WriteReport(pdfRenderer{}, reportData, namespace)
+}
+
+type pdfRenderer struct{}
+
+func (pdfRenderer) Extension() string { return "pdf" }
+
+func (pdfRenderer) Render(w io.Writer, reportData models.Report, namespace string) error {
 	pdf := gofpdf.New("P", "mm", "A4", "")
 	pdf.SetTitle("Kubernetes Resource Usage and Recommendations", true)
 	pdf.AddPage()
@@ -114,68 +230,131 @@ func PDFReport(reportData models.Report, namespace string) (string, error) {
 	} else {
 		pdf.Cell(200, 10, fmt.Sprintf("Namespace: %s", namespace))
 	}
-	pdf.Ln(10)
+	pdf.Ln(6)
+	if len(reportData.Clusters) > 0 {
+		pdf.Cell(200, 10, fmt.Sprintf("Clusters: %s", strings.Join(reportData.Clusters, ", ")))
+		pdf.Ln(6)
+	}
+	pdf.Ln(4)
+	if reportData.Summary.Total.Containers > 0 {
+		pdfSummary(pdf, reportData.Summary)
+		pdf.AddPage()
+	}
+	pdfLegend(pdf)
+	pdf.Ln(4)
+
+	if totals := namespaceTotals(reportData.Entries); len(totals) > 0 {
+		pdf.SetFont("Arial", "B", 14)
+		pdf.Cell(200, 10, "Requested vs Used by Namespace:")
+		pdf.Ln(10)
+		pdfNamespaceChart(pdf, totals)
+	}
 
 	// Detailed Report
 	pdf.SetFont("Arial", "B", 14)
 	pdf.Cell(200, 10, "Detailed Recommendations:")
 	pdf.Ln(10)
 
+	cluster := ""
+	window := reportWindow(reportData)
 	for _, entry := range reportData.Entries {
+		if entry.Cluster != cluster {
+			cluster = entry.Cluster
+			pdf.SetFont("Arial", "B", 13)
+			pdf.Cell(200, 10, fmt.Sprintf("Cluster: %s", cluster))
+			pdf.Ln(10)
+		}
 		pdf.SetFont("Arial", "B", 12)
-		pdf.Cell(200, 8, fmt.Sprintf("Workload: %s (%s)", entry.Workload.Name, entry.Workload.Namespace))
+		pdf.Cell(200, 8, fmt.Sprintf("%s: %s (%s)", entry.Workload.Kind, entry.Workload.Name, entry.Workload.Namespace))
 		pdf.Ln(6)
+		if entry.RecommendedPodRequest != nil {
+			pdf.SetFont("Arial", "", 10)
+			pdf.Cell(200, 5, fmt.Sprintf("  Effective Pod Request: CPU %s -> %s | Memory %s -> %s",
+				helper.QuantityToString(entry.CurrentPodRequest[v1.ResourceCPU]),
+				helper.QuantityToString(entry.RecommendedPodRequest[v1.ResourceCPU]),
+				helper.QuantityToString(entry.CurrentPodRequest[v1.ResourceMemory]),
+				helper.QuantityToString(entry.RecommendedPodRequest[v1.ResourceMemory])))
+			pdf.Ln(6)
+		}
 
+		if c := entry.Cost; c != nil {
+			pdf.SetFont("Arial", "", 10)
+			pdf.Cell(200, 5, fmt.Sprintf("  Monthly cost: %s -> %s %s (savings %s)",
+				formatMoney(c.Current), formatMoney(c.Recommended), c.Currency, formatMoney(c.Savings)))
+			pdf.Ln(6)
+		}
+		pdfResourceTable(pdf, entry)
 		for _, rec := range entry.Recommendation {
-			pdf.SetFont("Arial", "", 11)
-			pdf.Cell(200, 6, fmt.Sprintf("  Container: %s", rec.ContainerName))
+			pdf.SetFont("Arial", "B", 10)
+			pdf.Cell(200, 6, fmt.Sprintf("  Container: %s (%s)", rec.ContainerName, containerRole(entry.Workload, rec.ContainerName)))
 			pdf.Ln(5)
 
-			if rec.RecommendedRequest.Request != nil && rec.RecommendedLimit.Limits != nil {
-				cpuRequest := rec.RecommendedRequest.Request["cpu"]
-				cpuLimit := rec.RecommendedLimit.Limits["cpu"]
-				memRequest := rec.RecommendedRequest.Request["memory"]
-				memLimit := rec.RecommendedLimit.Limits["memory"]
+			pdf.SetFont("Arial", "I", 9)
+			pdf.Cell(200, 5, fmt.Sprintf("    (%s over the last %s)", rec.Reason, reportData.Lookback))
+			pdf.Ln(5)
+			pdf.SetFont("Arial", "", 10)
 
+			if len(rec.VPATarget) > 0 {
+				pdf.Cell(200, 5, fmt.Sprintf("    Existing VPA target: CPU %s | Memory %s",
+					helper.QuantityToString(rec.VPATarget[v1.ResourceCPU]),
+					helper.QuantityToString(rec.VPATarget[v1.ResourceMemory])))
+				pdf.Ln(5)
+			}
+
+			if len(rec.Violations) > 0 {
+				pdf.SetFont("Arial", "B", 10)
+				pdf.SetTextColor(200, 0, 0)
+				for _, v := range rec.Violations {
+					pdf.Cell(200, 5, fmt.Sprintf("    Policy violation: %s", v))
+					pdf.Ln(4)
+				}
+				pdf.SetTextColor(0, 0, 0)
 				pdf.SetFont("Arial", "", 10)
-				cpuReqStr := cpuRequest.String()
-				cpuLimStr := cpuLimit.String()
-				cpuReqCores := recommendation.MillicoresToCores(cpuReqStr)
-				cpuLimCores := recommendation.MillicoresToCores(cpuLimStr)
-				
-				pdf.Cell(200, 5, fmt.Sprintf(
-					"    Recommended CPU Request: %s (%.3f cores) | Recommended CPU Limit: %s (%.3f cores)",
-					cpuReqStr, cpuReqCores, cpuLimStr, cpuLimCores))
-				pdf.Ln(4)
-				
-				pdf.Cell(200, 5, fmt.Sprintf("    Recommended Memory Request: %s | Recommended Memory Limit: %s", memRequest.String(), memLimit.String()))
-				pdf.Ln(4)
-				pdf.SetFont("Arial", "I", 9)
-				pdf.Cell(200, 5, "    (Based on p95 for requests and p99 for limits over the last 9 hours)")
-				pdf.Ln(6)
-				pdf.SetFont("Arial", "", 10)
+				pdf.Ln(1)
 			}
 
 			// Display current resource usage
-			pdf.Cell(200, 5, fmt.Sprintf("    Current CPU Usage: %.2f cores", rec.UsageStats.CurrentCPU))
-			pdf.Ln(4)
-			pdf.Cell(200, 5, fmt.Sprintf("    Current Memory Usage: %.2f MiB", rec.UsageStats.CurrentMemory/1024/1024))
-			pdf.Ln(6)
+			if rec.UsageStats != nil {
+				pdf.Cell(200, 5, fmt.Sprintf("    Current Usage: CPU %.2f cores | Memory %.2f MiB",
+					rec.UsageStats.CurrentCPU, rec.UsageStats.CurrentMemory/1024/1024))
+				pdf.Ln(6)
+			}
+			pdfUsageCharts(pdf, window, entry.Workload, rec)
 		}
 		pdf.Ln(4)
 	}
 
-	// Generate filename with timestamp
-	reportFilename := fmt.Sprintf("k8s_resource_report_%s_%s.pdf",
-		namespace,
-		reportData.Timestamp.Format("20060102_150405"))
-
-	err := pdf.OutputFileAndClose(reportFilename)
-	if err != nil {
-		return "", fmt.Errorf("failed to save PDF report: %v", err)
+	if len(reportData.Comparisons) > 0 {
+		pdf.AddPage()
+		pdf.SetFont("Arial", "B", 14)
+		pdf.Cell(200, 10, "Cross-Cluster Comparison:")
+		pdf.Ln(10)
+		for _, c := range reportData.Comparisons {
+			pdf.SetFont("Arial", "B", 11)
+			pdf.Cell(200, 6, fmt.Sprintf("%s: %s (%s) / %s", c.Kind, c.Name, c.Namespace, c.Container))
+			pdf.Ln(6)
+			pdf.SetFont("Arial", "", 10)
+			for _, cr := range c.Clusters {
+				pdf.Cell(200, 5, fmt.Sprintf("    %s: CPU %s / %s | Memory %s / %s (request / limit)",
+					cr.Cluster,
+					helper.QuantityToString(cr.Request[v1.ResourceCPU]),
+					helper.QuantityToString(cr.Limit[v1.ResourceCPU]),
+					helper.QuantityToString(cr.Request[v1.ResourceMemory]),
+					helper.QuantityToString(cr.Limit[v1.ResourceMemory])))
+				pdf.Ln(5)
+			}
+			pdf.Ln(3)
+		}
 	}
 
-	fmt.Printf("PDF report saved as: %s\n", reportFilename)
-	return reportFilename, nil
+	return pdf.Output(w)
 }
 
+func containerRole(w models.WorkLoad, container string) models.ContainerRole {
+	for _, c := range w.Containers {
+		if c.Name == container {
+			return c.Role
+		}
+	}
+	return models.RoleApp
+}
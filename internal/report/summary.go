@@ -0,0 +1,281 @@
+package report
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/tabed23/k8s-resource-tuner/internal/models"
+	v1 "k8s.io/api/core/v1"
+)
+
+// provisioningPercent is how far a recommended value has to be from the
+// current one for a container to count as over or under provisioned.
+const provisioningPercent = 10
+
+// topWorkloads is the length of the rankings of the summary.
+const topWorkloads = 10
+
+type provisioning int
+
+const (
+	wellProvisioned provisioning = iota
+	overProvisioned
+	underProvisioned
+)
+
+// classify tells whether a recommended value is well below the current
+// one or above it. A value only set on one side is neither.
+func classify(c Change) provisioning {
+	switch {
+	case c.Recommended == "" || c.Current == "":
+		return wellProvisioned
+	case c.DeltaPercent < -provisioningPercent:
+		return overProvisioned
+	case c.DeltaPercent > provisioningPercent:
+		return underProvisioned
+	}
+	return wellProvisioned
+}
+
+// containerProvisioning classifies a container by its CPU and memory
+// requests, which are what the scheduler reserves. A request that is short
+// makes the container under-provisioned, otherwise a request well above the
+// recommendation makes it over-provisioned.
+func containerProvisioning(e models.ReportEntry, rec models.Recommendation) provisioning {
+	current := containerResources(e.Workload, rec.ContainerName)
+	state := wellProvisioned
+	for _, name := range []v1.ResourceName{v1.ResourceCPU, v1.ResourceMemory} {
+		c := newChange(e, rec.ContainerName, name, "request", current.Request, rec.RecommendedRequest.Request)
+		if p := classify(c); p > state {
+			state = p
+		}
+	}
+	return state
+}
+
+// Summarize totals the requested and recommended CPU and memory of the
+// app and sidecar containers of the entries, cluster-wide, by cluster and
+// by namespace, and ranks the most wasteful and most at-risk workloads.
+// Init containers are left out since they do not run next to the others.
+// When the entries are priced, costs are totaled by team as well and the
+// most wasteful workloads are those with the largest savings.
+func Summarize(entries []models.ReportEntry) models.Summary {
+	var s models.Summary
+	clusters := map[string]*models.ScopeTotals{}
+	namespaces := map[string]*models.ScopeTotals{}
+	teams := map[string]*models.ScopeTotals{}
+	type ranked struct {
+		totals models.WorkloadTotals
+		// waste and shortfall are the requested CPU and memory above,
+		// respectively below, the recommendation.
+		waste, shortfall [2]float64
+	}
+	var workloads []ranked
+
+	for _, e := range entries {
+		w := ranked{totals: models.WorkloadTotals{
+			Cluster:   e.Cluster,
+			Namespace: e.Workload.Namespace,
+			Kind:      e.Workload.Kind,
+			Name:      e.Workload.Name,
+		}}
+		t := &w.totals.Totals
+		n := e.ReplicaCount()
+		for _, rec := range e.Recommendation {
+			if containerRole(e.Workload, rec.ContainerName) == models.RoleInit {
+				continue
+			}
+			t.Containers++
+			switch containerProvisioning(e, rec) {
+			case overProvisioned:
+				t.OverProvisioned++
+			case underProvisioned:
+				t.UnderProvisioned++
+			}
+			current := containerResources(e.Workload, rec.ContainerName)
+			for i, r := range []struct {
+				name                   v1.ResourceName
+				scale                  float64
+				requested, recommended *float64
+			}{
+				{v1.ResourceCPU, 1, &t.CPURequested, &t.CPURecommended},
+				{v1.ResourceMemory, gibibyte, &t.MemoryRequested, &t.MemoryRecommended},
+			} {
+				var cur, recommended float64
+				if q, ok := current.Request[r.name]; ok {
+					cur = q.AsApproximateFloat64() / r.scale * n
+				}
+				if q, ok := rec.RecommendedRequest.Request[r.name]; ok {
+					recommended = q.AsApproximateFloat64() / r.scale * n
+				}
+				*r.requested += cur
+				*r.recommended += recommended
+				w.waste[i] += math.Max(0, cur-recommended)
+				w.shortfall[i] += math.Max(0, recommended-cur)
+			}
+		}
+		if e.Cost != nil {
+			s.Currency = e.Cost.Currency
+			t.CurrentCost, t.RecommendedCost, t.Savings = e.Cost.Current, e.Cost.Recommended, e.Cost.Savings
+			team, ok := teams[e.Team]
+			if !ok {
+				team = &models.ScopeTotals{Team: e.Team}
+				teams[e.Team] = team
+			}
+			addTotals(&team.Totals, *t)
+		}
+		workloads = append(workloads, w)
+
+		addTotals(&s.Total, *t)
+		if e.Cluster != "" {
+			c, ok := clusters[e.Cluster]
+			if !ok {
+				c = &models.ScopeTotals{Cluster: e.Cluster}
+				clusters[e.Cluster] = c
+			}
+			addTotals(&c.Totals, *t)
+		}
+		key := e.Cluster + "/" + e.Workload.Namespace
+		ns, ok := namespaces[key]
+		if !ok {
+			ns = &models.ScopeTotals{Cluster: e.Cluster, Namespace: e.Workload.Namespace}
+			namespaces[key] = ns
+		}
+		addTotals(&ns.Totals, *t)
+	}
+	roundTotals(&s.Total)
+	s.Clusters = sortedScopes(clusters)
+	s.Namespaces = sortedScopes(namespaces)
+	s.Teams = sortedScopes(teams)
+
+	// Workloads are ranked by the share of the cluster-wide requests they
+	// would free or need, so that CPU and memory weigh the same.
+	score := func(v [2]float64) float64 {
+		return share(v[0], s.Total.CPURequested) + share(v[1], s.Total.MemoryRequested)
+	}
+	top := func(include func(ranked) bool, value func(ranked) float64) []models.WorkloadTotals {
+		var selected []ranked
+		for _, w := range workloads {
+			if include(w) {
+				selected = append(selected, w)
+			}
+		}
+		sort.SliceStable(selected, func(i, j int) bool {
+			return value(selected[i]) > value(selected[j])
+		})
+		var totals []models.WorkloadTotals
+		for i := 0; i < len(selected) && i < topWorkloads; i++ {
+			roundTotals(&selected[i].totals.Totals)
+			totals = append(totals, selected[i].totals)
+		}
+		return totals
+	}
+	waste := func(w ranked) float64 { return score(w.waste) }
+	if s.Currency != "" {
+		waste = func(w ranked) float64 { return w.totals.Savings }
+	}
+	s.TopWasteful = top(
+		func(w ranked) bool { return w.totals.OverProvisioned > 0 && waste(w) > 0 },
+		waste)
+	s.TopAtRisk = top(
+		func(w ranked) bool { return w.totals.UnderProvisioned > 0 },
+		func(w ranked) float64 { return score(w.shortfall) })
+	return s
+}
+
+func addTotals(t *models.Totals, o models.Totals) {
+	t.Containers += o.Containers
+	t.OverProvisioned += o.OverProvisioned
+	t.UnderProvisioned += o.UnderProvisioned
+	t.CPURequested += o.CPURequested
+	t.CPURecommended += o.CPURecommended
+	t.MemoryRequested += o.MemoryRequested
+	t.MemoryRecommended += o.MemoryRecommended
+	t.CurrentCost += o.CurrentCost
+	t.RecommendedCost += o.RecommendedCost
+	t.Savings += o.Savings
+}
+
+// roundTotals drops the floating point noise of the sums, keeping
+// millicores, MiB-level precision and cents.
+func roundTotals(t *models.Totals) {
+	for _, v := range []*float64{&t.CPURequested, &t.CPURecommended, &t.MemoryRequested, &t.MemoryRecommended} {
+		*v = math.Round(*v*1000) / 1000
+	}
+	for _, v := range []*float64{&t.CurrentCost, &t.RecommendedCost, &t.Savings} {
+		*v = math.Round(*v*100) / 100
+	}
+}
+
+func sortedScopes(scopes map[string]*models.ScopeTotals) []models.ScopeTotals {
+	keys := make([]string, 0, len(scopes))
+	for k := range scopes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	sorted := make([]models.ScopeTotals, 0, len(keys))
+	for _, k := range keys {
+		roundTotals(&scopes[k].Totals)
+		sorted = append(sorted, *scopes[k])
+	}
+	return sorted
+}
+
+// share returns v as a fraction of total, or v itself when nothing is
+// requested at all.
+func share(v, total float64) float64 {
+	if total == 0 {
+		return v
+	}
+	return v / total
+}
+
+// scopeName names a cluster or namespace of the summary.
+func scopeName(s models.ScopeTotals) string {
+	switch {
+	case s.Namespace == "":
+		return "cluster " + s.Cluster
+	case s.Cluster == "":
+		return s.Namespace
+	}
+	return s.Cluster + " / " + s.Namespace
+}
+
+// teamName names a team of the summary.
+func teamName(s models.ScopeTotals) string {
+	if s.Team == "" {
+		return "no team label"
+	}
+	return "team " + s.Team
+}
+
+// workloadName names a workload of the summary rankings.
+func workloadName(w models.WorkloadTotals) string {
+	name := fmt.Sprintf("%s %s/%s", w.Kind, w.Namespace, w.Name)
+	if w.Cluster != "" {
+		name = w.Cluster + ": " + name
+	}
+	return name
+}
+
+func formatCores(v float64) string {
+	return fmt.Sprintf("%.2f cores", v)
+}
+
+func formatGiB(v float64) string {
+	return fmt.Sprintf("%.2f GiB", v)
+}
+
+// costHeader titles the cost columns of the summary tables.
+func costHeader(currency string) []string {
+	return []string{
+		fmt.Sprintf("Monthly cost (%s)", currency),
+		fmt.Sprintf("Recommended cost (%s)", currency),
+		fmt.Sprintf("Monthly savings (%s)", currency),
+	}
+}
+
+func formatMoney(v float64) string {
+	return fmt.Sprintf("%.2f", v)
+}
@@ -0,0 +1,175 @@
+package report
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/tabed23/k8s-resource-tuner/internal/models"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func list(values ...string) v1.ResourceList {
+	l := v1.ResourceList{}
+	for i := 0; i+1 < len(values); i += 2 {
+		l[v1.ResourceName(values[i])] = resource.MustParse(values[i+1])
+	}
+	return l
+}
+
+type testContainer struct {
+	name                 string
+	role                 models.ContainerRole
+	current, recommended models.ResourceConfig
+}
+
+func requests(cpu, memory string) models.ResourceConfig {
+	return models.ResourceConfig{Request: list("cpu", cpu, "memory", memory)}
+}
+
+// testEntry builds the entry of a Deployment with the given number of pods.
+func testEntry(namespace, name string, pods int, containers ...testContainer) models.ReportEntry {
+	e := models.ReportEntry{Workload: models.WorkLoad{Namespace: namespace, Name: name, Kind: models.KindDeployment}}
+	for i := 0; i < pods; i++ {
+		e.Workload.Pods = append(e.Workload.Pods, fmt.Sprintf("%s-%d", name, i))
+	}
+	for _, c := range containers {
+		if c.role == "" {
+			c.role = models.RoleApp
+		}
+		e.Workload.Containers = append(e.Workload.Containers, models.ContainerSpec{Name: c.name, Role: c.role, Resources: c.current})
+		e.Recommendation = append(e.Recommendation, models.Recommendation{
+			ContainerName:      c.name,
+			RecommendedRequest: models.ResourceConfig{Request: c.recommended.Request},
+			RecommendedLimit:   models.ResourceConfig{Limits: c.recommended.Limits},
+		})
+	}
+	return e
+}
+
+func TestContainerProvisioning(t *testing.T) {
+	tests := []struct {
+		name                 string
+		current, recommended models.ResourceConfig
+		want                 provisioning
+	}{
+		{"unchanged", requests("500m", "512Mi"), requests("500m", "512Mi"), wellProvisioned},
+		{"within threshold", requests("500m", "512Mi"), requests("540m", "480Mi"), wellProvisioned},
+		{"over-provisioned", requests("1", "1Gi"), requests("500m", "1Gi"), overProvisioned},
+		{"under-provisioned", requests("100m", "1Gi"), requests("300m", "1Gi"), underProvisioned},
+		{"a short request outranks an over-provisioned one", requests("100m", "1Gi"), requests("300m", "256Mi"), underProvisioned},
+		{
+			"a short limit does not outrank an over-provisioned request",
+			models.ResourceConfig{Request: list("cpu", "1", "memory", "1Gi"), Limits: list("memory", "1Gi")},
+			models.ResourceConfig{Request: list("cpu", "500m", "memory", "1Gi"), Limits: list("memory", "2Gi")},
+			overProvisioned,
+		},
+		{
+			"missing current limit",
+			requests("500m", "512Mi"),
+			models.ResourceConfig{Request: list("cpu", "500m", "memory", "512Mi"), Limits: list("memory", "1Gi")},
+			wellProvisioned,
+		},
+		{"missing current request", models.ResourceConfig{}, requests("500m", "512Mi"), wellProvisioned},
+		{"no recommendation", requests("500m", "512Mi"), models.ResourceConfig{}, wellProvisioned},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := testEntry("shop", "api", 1, testContainer{name: "app", current: tt.current, recommended: tt.recommended})
+			if got := containerProvisioning(e, e.Recommendation[0]); got != tt.want {
+				t.Errorf("containerProvisioning = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	api := testEntry("shop", "api", 2,
+		testContainer{name: "app", current: requests("1", "1Gi"), recommended: requests("500m", "512Mi")},
+		testContainer{name: "istio-proxy", role: models.RoleSidecar, current: requests("100m", "128Mi"), recommended: requests("100m", "128Mi")},
+		testContainer{name: "migrate", role: models.RoleInit, current: requests("2", "2Gi"), recommended: requests("100m", "64Mi")},
+	)
+	worker := testEntry("shop", "worker", 1,
+		testContainer{name: "app", current: requests("100m", "256Mi"), recommended: requests("300m", "128Mi")})
+	web := testEntry("shop", "web", 1, testContainer{
+		name:        "app",
+		current:     requests("200m", "256Mi"),
+		recommended: models.ResourceConfig{Request: list("cpu", "200m", "memory", "256Mi"), Limits: list("memory", "512Mi")},
+	})
+	report := testEntry("batch", "report", 3,
+		testContainer{name: "app", current: requests("2", "2Gi"), recommended: requests("1", "1Gi")})
+	report.Workload.Kind = models.KindCronJob
+	report.Replicas = 0.5
+
+	s := Summarize([]models.ReportEntry{api, worker, web, report})
+
+	wantTotal := models.Totals{
+		Containers: 5, OverProvisioned: 2, UnderProvisioned: 1,
+		CPURequested: 3.5, CPURecommended: 2.2,
+		MemoryRequested: 3.75, MemoryRecommended: 2.125,
+	}
+	if s.Total != wantTotal {
+		t.Errorf("total = %+v, want %+v", s.Total, wantTotal)
+	}
+	wantNamespaces := []models.ScopeTotals{
+		{Namespace: "batch", Totals: models.Totals{Containers: 1, OverProvisioned: 1,
+			CPURequested: 1, CPURecommended: 0.5, MemoryRequested: 1, MemoryRecommended: 0.5}},
+		{Namespace: "shop", Totals: models.Totals{Containers: 4, OverProvisioned: 1, UnderProvisioned: 1,
+			CPURequested: 2.5, CPURecommended: 1.7, MemoryRequested: 2.75, MemoryRecommended: 1.625}},
+	}
+	if !reflect.DeepEqual(s.Namespaces, wantNamespaces) {
+		t.Errorf("namespaces = %+v, want %+v", s.Namespaces, wantNamespaces)
+	}
+	if len(s.Clusters) != 0 || len(s.Teams) != 0 || s.Currency != "" {
+		t.Errorf("unexpected clusters %v, teams %v or currency %q", s.Clusters, s.Teams, s.Currency)
+	}
+	if got := workloadNames(s.TopWasteful); !reflect.DeepEqual(got, []string{"api", "report"}) {
+		t.Errorf("top wasteful = %v, want [api report]", got)
+	}
+	if got := workloadNames(s.TopAtRisk); !reflect.DeepEqual(got, []string{"worker"}) {
+		t.Errorf("top at risk = %v, want [worker]", got)
+	}
+}
+
+func TestSummarizePriced(t *testing.T) {
+	api := testEntry("shop", "api", 2,
+		testContainer{name: "app", current: requests("1", "1Gi"), recommended: requests("500m", "512Mi")})
+	api.Cluster, api.Team = "prod", "payments"
+	api.Cost = &models.Cost{Currency: "USD", Current: 100, Recommended: 90, Savings: 10}
+	report := testEntry("batch", "report", 1,
+		testContainer{name: "app", current: requests("200m", "256Mi"), recommended: requests("100m", "128Mi")})
+	report.Cluster, report.Team = "prod", "data"
+	report.Cost = &models.Cost{Currency: "USD", Current: 80, Recommended: 30, Savings: 50}
+
+	s := Summarize([]models.ReportEntry{api, report})
+
+	if s.Currency != "USD" {
+		t.Errorf("currency = %q, want USD", s.Currency)
+	}
+	if s.Total.CurrentCost != 180 || s.Total.RecommendedCost != 120 || s.Total.Savings != 60 {
+		t.Errorf("total cost = %+v", s.Total)
+	}
+	if len(s.Clusters) != 1 || s.Clusters[0].Cluster != "prod" || s.Clusters[0].Containers != 2 {
+		t.Errorf("clusters = %+v", s.Clusters)
+	}
+	var teams []string
+	for _, team := range s.Teams {
+		teams = append(teams, fmt.Sprintf("%s:%g", team.Team, team.Savings))
+	}
+	if !reflect.DeepEqual(teams, []string{"data:50", "payments:10"}) {
+		t.Errorf("teams = %v", teams)
+	}
+	// Priced workloads rank by savings, not by the share of the requests.
+	if got := workloadNames(s.TopWasteful); !reflect.DeepEqual(got, []string{"report", "api"}) {
+		t.Errorf("top wasteful = %v, want [report api]", got)
+	}
+}
+
+func workloadNames(workloads []models.WorkloadTotals) []string {
+	var names []string
+	for _, w := range workloads {
+		names = append(names, w.Name)
+	}
+	return names
+}
@@ -36,6 +36,33 @@ func Avg(values []float64) float64 {
 	return sum / float64(len(values))
 }
 
+// Values returns the values of the points.
+func Values(points []models.Point) []float64 {
+	values := make([]float64, len(points))
+	for i, p := range points {
+		values[i] = p.Value
+	}
+	return values
+}
+
+// MaxPerStep merges the series of several pods into one, keeping the highest
+// value at each timestamp, oldest first.
+func MaxPerStep(points []models.Point) []models.Point {
+	byTime := map[int64]int{}
+	var merged []models.Point
+	for _, p := range points {
+		key := p.Timestamp.UnixNano()
+		if i, ok := byTime[key]; ok {
+			merged[i].Value = math.Max(merged[i].Value, p.Value)
+			continue
+		}
+		byTime[key] = len(merged)
+		merged = append(merged, p)
+	}
+	sort.Slice(merged, func(i, j int) bool { return merged[i].Timestamp.Before(merged[j].Timestamp) })
+	return merged
+}
+
 func BuildUsageStats(containerName string, cpuSamples, memSamples []float64) models.UsageStats {
 	return models.UsageStats{
 		ContainerName: containerName,
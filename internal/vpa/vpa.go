@@ -0,0 +1,241 @@
+package vpa
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tabed23/k8s-resource-tuner/internal/k8s"
+	"github.com/tabed23/k8s-resource-tuner/internal/models"
+	"github.com/tabed23/k8s-resource-tuner/internal/recommendation"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"sigs.k8s.io/yaml"
+)
+
+// UpdateMode is the VPA update mode of the generated objects. Only the modes
+// that never evict running pods are offered.
+type UpdateMode string
+
+const (
+	// UpdateModeOff only publishes recommendations in the VPA status.
+	UpdateModeOff UpdateMode = "Off"
+	// UpdateModeInitial sets resources when pods are created.
+	UpdateModeInitial UpdateMode = "Initial"
+)
+
+// VerticalPodAutoscaler mirrors the autoscaling.k8s.io/v1 fields the tuner
+// generates.
+type VerticalPodAutoscaler struct {
+	APIVersion string   `json:"apiVersion"`
+	Kind       string   `json:"kind"`
+	Metadata   Metadata `json:"metadata"`
+	Spec       Spec     `json:"spec"`
+}
+
+type Metadata struct {
+	Name      string            `json:"name"`
+	Namespace string            `json:"namespace"`
+	Labels    map[string]string `json:"labels,omitempty"`
+}
+
+type Spec struct {
+	TargetRef      TargetRef      `json:"targetRef"`
+	UpdatePolicy   UpdatePolicy   `json:"updatePolicy"`
+	ResourcePolicy ResourcePolicy `json:"resourcePolicy"`
+}
+
+type TargetRef struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+}
+
+type UpdatePolicy struct {
+	UpdateMode UpdateMode `json:"updateMode"`
+}
+
+type ResourcePolicy struct {
+	ContainerPolicies []ContainerPolicy `json:"containerPolicies"`
+}
+
+type ContainerPolicy struct {
+	ContainerName       string            `json:"containerName"`
+	Mode                string            `json:"mode,omitempty"`
+	MinAllowed          v1.ResourceList   `json:"minAllowed,omitempty"`
+	MaxAllowed          v1.ResourceList   `json:"maxAllowed,omitempty"`
+	ControlledResources []v1.ResourceName `json:"controlledResources,omitempty"`
+	ControlledValues    string            `json:"controlledValues,omitempty"`
+}
+
+// ParseUpdateMode validates an update mode given in the configuration.
+func ParseUpdateMode(s string) (UpdateMode, error) {
+	switch m := UpdateMode(s); m {
+	case UpdateModeOff, UpdateModeInitial:
+		return m, nil
+	default:
+		return "", fmt.Errorf("unsupported VPA update mode %q, use Off or Initial", s)
+	}
+}
+
+// Manifest builds the VerticalPodAutoscaler of a workload. The allowed range
+// of each container spans the recommended request and limit widened by the
+// policy's safety margin, within the policy's min and max bounds, so the VPA
+// stays close to what the tuner recommends. Init containers are left out
+// since VPA does not manage them. The object is named after the kind and
+// name of the workload, like its file, so workloads of different kinds
+// sharing a name get VPAs of their own.
+func Manifest(w models.WorkLoad, recs []models.Recommendation, policy recommendation.Policy, mode UpdateMode) (VerticalPodAutoscaler, error) {
+	apiVersion, err := targetAPIVersion(w.Kind)
+	if err != nil {
+		return VerticalPodAutoscaler{}, err
+	}
+	vpa := VerticalPodAutoscaler{
+		APIVersion: "autoscaling.k8s.io/v1",
+		Kind:       "VerticalPodAutoscaler",
+		Metadata: Metadata{
+			Name:      strings.ToLower(w.Kind) + "-" + w.Name,
+			Namespace: w.Namespace,
+			Labels:    map[string]string{"app.kubernetes.io/managed-by": "k8s-resource-tuner"},
+		},
+		Spec: Spec{
+			TargetRef:    TargetRef{APIVersion: apiVersion, Kind: w.Kind, Name: w.Name},
+			UpdatePolicy: UpdatePolicy{UpdateMode: mode},
+		},
+	}
+	for _, c := range w.Containers {
+		if c.Role == models.RoleInit {
+			continue
+		}
+		var rec *models.Recommendation
+		for i := range recs {
+			if recs[i].ContainerName == c.Name {
+				rec = &recs[i]
+			}
+		}
+		if rec == nil {
+			vpa.Spec.ResourcePolicy.ContainerPolicies = append(vpa.Spec.ResourcePolicy.ContainerPolicies,
+				ContainerPolicy{ContainerName: c.Name, Mode: "Off"})
+			continue
+		}
+		cp := ContainerPolicy{
+			ContainerName:       c.Name,
+			MinAllowed:          v1.ResourceList{},
+			MaxAllowed:          v1.ResourceList{},
+			ControlledResources: []v1.ResourceName{v1.ResourceCPU, v1.ResourceMemory},
+			ControlledValues:    "RequestsAndLimits",
+		}
+		if policy.CPULimit == recommendation.LimitNone && policy.QoS != v1.PodQOSGuaranteed {
+			// Scaling limits proportionally would bring back the CPU
+			// limit the policy leaves out.
+			cp.ControlledValues = "RequestsOnly"
+		}
+		for _, b := range []struct {
+			name v1.ResourceName
+			rp   recommendation.ResourcePolicy
+		}{{v1.ResourceCPU, policy.CPU}, {v1.ResourceMemory, policy.Memory}} {
+			min, max, ok := bounds(rec, b.name, b.rp)
+			if !ok {
+				continue
+			}
+			cp.MinAllowed[b.name] = quantity(b.name, min)
+			cp.MaxAllowed[b.name] = quantity(b.name, max)
+		}
+		vpa.Spec.ResourcePolicy.ContainerPolicies = append(vpa.Spec.ResourcePolicy.ContainerPolicies, cp)
+	}
+	return vpa, nil
+}
+
+// bounds returns the allowed range of one resource in cores or bytes: the
+// recommended request less the margin up to the recommended limit (or the
+// request when there is none) plus the margin.
+func bounds(rec *models.Recommendation, name v1.ResourceName, rp recommendation.ResourcePolicy) (float64, float64, bool) {
+	request, ok := rec.RecommendedRequest.Request[name]
+	if !ok {
+		return 0, 0, false
+	}
+	upper := request
+	if limit, ok := rec.RecommendedLimit.Limits[name]; ok && limit.Cmp(upper) > 0 {
+		upper = limit
+	}
+	margin := rp.MarginPercent / 100
+	min := request.AsApproximateFloat64() / (1 + margin)
+	max := upper.AsApproximateFloat64() * (1 + margin)
+	if floor := rp.Min.AsApproximateFloat64(); min < floor {
+		min = floor
+	}
+	if ceiling := rp.Max.AsApproximateFloat64(); ceiling > 0 && max > ceiling {
+		max = ceiling
+	}
+	if max < min {
+		max = min
+	}
+	return min, max, true
+}
+
+func quantity(name v1.ResourceName, value float64) resource.Quantity {
+	if name == v1.ResourceCPU {
+		return *resource.NewMilliQuantity(int64(math.Ceil(value*1000)), resource.DecimalSI)
+	}
+	const mib = 1024 * 1024
+	return *resource.NewQuantity(int64(math.Ceil(value/mib))*mib, resource.BinarySI)
+}
+
+func targetAPIVersion(kind string) (string, error) {
+	switch kind {
+	case models.KindDeployment, models.KindStatefulSet, models.KindDaemonSet:
+		return "apps/v1", nil
+	case models.KindJob, models.KindCronJob:
+		return "batch/v1", nil
+	default:
+		return "", fmt.Errorf("no VPA target for kind %s", kind)
+	}
+}
+
+// WriteFiles writes the VerticalPodAutoscaler of every entry to
+// <dir>/[<cluster>/]<namespace>/<kind>-<name>.vpa.yaml and returns the
+// paths of the written files.
+func WriteFiles(dir string, entries []models.ReportEntry, policies recommendation.PolicySet, mode UpdateMode) ([]string, error) {
+	var written []string
+	for _, e := range entries {
+		w := e.Workload
+		policy, _ := policies.For(w)
+		vpa, err := Manifest(w, e.Recommendation, policy, mode)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Skipping VPA for %s %s/%s: %v\n", w.Kind, w.Namespace, w.Name, err)
+			continue
+		}
+		data, err := yaml.Marshal(vpa)
+		if err != nil {
+			return written, fmt.Errorf("failed to encode VPA: %v", err)
+		}
+		path := filepath.Join(dir, e.Cluster, w.Namespace, fmt.Sprintf("%s-%s.vpa.yaml", strings.ToLower(w.Kind), w.Name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return written, fmt.Errorf("failed to create VPA directory: %v", err)
+		}
+		if err := os.WriteFile(path, append([]byte("# Generated by k8s-resource-tuner\n"), data...), 0o644); err != nil {
+			return written, fmt.Errorf("failed to write VPA: %v", err)
+		}
+		written = append(written, path)
+	}
+	return written, nil
+}
+
+// AttachTargets sets the VPATarget of the recommendations of every entry
+// from the VerticalPodAutoscaler targeting its workload, if any.
+func AttachTargets(entries []models.ReportEntry, vpas []k8s.VPA) {
+	for i := range entries {
+		w := entries[i].Workload
+		for _, v := range vpas {
+			if v.TargetKind != w.Kind || v.TargetName != w.Name {
+				continue
+			}
+			for j := range entries[i].Recommendation {
+				rec := &entries[i].Recommendation[j]
+				rec.VPATarget = v.Targets[rec.ContainerName]
+			}
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package vpa
+
+import (
+	"os"
+	"testing"
+
+	"github.com/tabed23/k8s-resource-tuner/internal/models"
+	"github.com/tabed23/k8s-resource-tuner/internal/recommendation"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"sigs.k8s.io/yaml"
+)
+
+func entry(kind string) models.ReportEntry {
+	return models.ReportEntry{
+		Workload: models.WorkLoad{
+			Namespace:  "shop",
+			Name:       "api",
+			Kind:       kind,
+			Containers: []models.ContainerSpec{{Name: "app", Role: models.RoleApp}},
+		},
+		Recommendation: []models.Recommendation{{
+			ContainerName: "app",
+			RecommendedRequest: models.ResourceConfig{Request: v1.ResourceList{
+				v1.ResourceCPU:    resource.MustParse("100m"),
+				v1.ResourceMemory: resource.MustParse("128Mi"),
+			}},
+		}},
+	}
+}
+
+func TestWriteFilesSharedName(t *testing.T) {
+	dir := t.TempDir()
+	entries := []models.ReportEntry{entry(models.KindDeployment), entry(models.KindStatefulSet), entry(models.KindCronJob)}
+
+	written, err := WriteFiles(dir, entries, recommendation.DefaultPolicySet(), UpdateModeOff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(written) != len(entries) {
+		t.Fatalf("written = %v, want %d files", written, len(entries))
+	}
+	names := map[string]bool{}
+	for i, path := range written {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var vpa VerticalPodAutoscaler
+		if err := yaml.Unmarshal(data, &vpa); err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		kind := entries[i].Workload.Kind
+		if vpa.Spec.TargetRef.Kind != kind || vpa.Spec.TargetRef.Name != "api" {
+			t.Errorf("%s: target = %+v, want %s api", path, vpa.Spec.TargetRef, kind)
+		}
+		if names[vpa.Metadata.Name] {
+			t.Errorf("%s: VPA name %s is used twice", path, vpa.Metadata.Name)
+		}
+		names[vpa.Metadata.Name] = true
+	}
+	for _, want := range []string{"deployment-api", "statefulset-api", "cronjob-api"} {
+		if !names[want] {
+			t.Errorf("names = %v, want %s", names, want)
+		}
+	}
+}